@@ -0,0 +1,287 @@
+// Package apikeys manages the API keys clients authenticate with. Only a hash
+// of each key is stored; the key itself is shown once, when it is created.
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/db"
+	"github.com/lib/pq"
+)
+
+const (
+	ScopeRegister = "register"
+	ScopeVerify   = "verify"
+	ScopeIdentify = "identify"
+	ScopeAdmin    = "admin"
+)
+
+var Scopes = []string{ScopeRegister, ScopeVerify, ScopeIdentify, ScopeAdmin}
+
+// publishableScopes are the scopes a key embedded in a web page may carry.
+var publishableScopes = map[string]bool{ScopeRegister: true, ScopeVerify: true}
+
+const (
+	secretPrefix      = "fw_sk_"
+	publishablePrefix = "fw_pk_"
+	// shownPrefixLength is how much of a key is kept in clear to tell keys
+	// apart in listings.
+	shownPrefixLength = 12
+)
+
+var (
+	ErrUnknownKey = errors.New("apikeys: unknown or revoked key")
+	ErrNotFound   = errors.New("apikeys: key not found")
+	ErrInvalid    = errors.New("invalid API key settings")
+)
+
+type Key struct {
+	ID             int
+	TenantID       int
+	Name           string
+	Prefix         string
+	Publishable    bool
+	Scopes         []string
+	AllowedOrigins []string
+	CreatedAt      time.Time
+	RevokedAt      *time.Time
+}
+
+func (k *Key) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsOrigin reports whether a browser request from origin may use the
+// key. Secret keys are not limited to origins.
+func (k *Key) AllowsOrigin(origin string) bool {
+	if !k.Publishable {
+		return true
+	}
+	for _, allowed := range k.AllowedOrigins {
+		if strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Create stores a new key and returns it with the raw key, which cannot be
+// recovered later. Publishable keys are meant for the browser widget: they
+// need allowed origins and may only register and verify.
+func Create(ctx context.Context, tenantID int, name string, scopes []string, publishable bool, origins []string) (Key, string, error) {
+	if err := validate(name, scopes, publishable, origins); err != nil {
+		return Key{}, "", err
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return Key{}, "", err
+	}
+	raw := secretPrefix
+	if publishable {
+		raw = publishablePrefix
+	}
+	raw += base64.RawURLEncoding.EncodeToString(random)
+
+	if origins == nil {
+		origins = []string{}
+	}
+	key := Key{
+		TenantID:       tenantID,
+		Name:           name,
+		Prefix:         raw[:shownPrefixLength],
+		Publishable:    publishable,
+		Scopes:         scopes,
+		AllowedOrigins: origins,
+	}
+
+	query := `
+		INSERT INTO api_keys (
+			tenant_id,
+			name,
+			prefix,
+			key_hash,
+			publishable,
+			scopes,
+			allowed_origins
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`
+	err := db.DB.QueryRowContext(ctx, query,
+		key.TenantID,
+		key.Name,
+		key.Prefix,
+		hashKey(raw),
+		key.Publishable,
+		pq.Array(key.Scopes),
+		pq.Array(key.AllowedOrigins),
+	).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return Key{}, "", err
+	}
+	return key, raw, nil
+}
+
+func validate(name string, scopes []string, publishable bool, origins []string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalid)
+	}
+	if len(scopes) == 0 {
+		return fmt.Errorf("%w: at least one scope is required", ErrInvalid)
+	}
+	for _, scope := range scopes {
+		known := false
+		for _, s := range Scopes {
+			known = known || s == scope
+		}
+		if !known {
+			return fmt.Errorf("%w: unknown scope %q", ErrInvalid, scope)
+		}
+		if publishable && !publishableScopes[scope] {
+			return fmt.Errorf("%w: publishable keys cannot have the %s scope", ErrInvalid, scope)
+		}
+	}
+
+	if !publishable {
+		if len(origins) > 0 {
+			return fmt.Errorf("%w: only publishable keys are limited to origins", ErrInvalid)
+		}
+		return nil
+	}
+	if len(origins) == 0 {
+		return fmt.Errorf("%w: publishable keys need at least one allowed origin", ErrInvalid)
+	}
+	for _, origin := range origins {
+		parsed, err := url.Parse(origin)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" || (parsed.Path != "" && parsed.Path != "/") {
+			return fmt.Errorf("%w: invalid origin %q, expected scheme://host[:port]", ErrInvalid, origin)
+		}
+	}
+	return nil
+}
+
+// Lookup returns the active key matching raw.
+func Lookup(ctx context.Context, raw string) (*Key, error) {
+	query := `
+		SELECT
+			id,
+			tenant_id,
+			name,
+			prefix,
+			publishable,
+			scopes,
+			allowed_origins,
+			created_at
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL`
+	var key Key
+	err := db.DB.QueryRowContext(ctx, query, hashKey(raw)).Scan(
+		&key.ID,
+		&key.TenantID,
+		&key.Name,
+		&key.Prefix,
+		&key.Publishable,
+		pq.Array(&key.Scopes),
+		pq.Array(&key.AllowedOrigins),
+		&key.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrUnknownKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// List returns every key of a tenant, revoked ones included, newest first.
+func List(ctx context.Context, tenantID int) ([]Key, error) {
+	query := `
+		SELECT
+			id,
+			tenant_id,
+			name,
+			prefix,
+			publishable,
+			scopes,
+			allowed_origins,
+			created_at,
+			revoked_at
+		FROM api_keys
+		WHERE tenant_id = $1
+		ORDER BY id DESC`
+	rows, err := db.DB.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []Key{}
+	for rows.Next() {
+		var key Key
+		if err := rows.Scan(
+			&key.ID,
+			&key.TenantID,
+			&key.Name,
+			&key.Prefix,
+			&key.Publishable,
+			pq.Array(&key.Scopes),
+			pq.Array(&key.AllowedOrigins),
+			&key.CreatedAt,
+			&key.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke disables a key of a tenant. Revoking a revoked key is not an error.
+func Revoke(ctx context.Context, tenantID, id int) error {
+	query := `
+		UPDATE api_keys SET
+			revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1 AND tenant_id = $2`
+	result, err := db.DB.ExecContext(ctx, query, id, tenantID)
+	if err != nil {
+		return err
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func hashKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the key the request was
+// authenticated with.
+func NewContext(ctx context.Context, key *Key) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// FromContext returns the key of the request, or nil.
+func FromContext(ctx context.Context) *Key {
+	key, _ := ctx.Value(contextKey{}).(*Key)
+	return key
+}
@@ -0,0 +1,42 @@
+package apikeys
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		keyName     string
+		scopes      []string
+		publishable bool
+		origins     []string
+		wantErr     bool
+	}{
+		{"secret key", "backend", []string{ScopeRegister, ScopeAdmin}, false, nil, false},
+		{"publishable key", "widget", []string{ScopeVerify}, true, []string{"https://shop.example.com"}, false},
+		{"origin with port and slash", "widget", []string{ScopeVerify}, true, []string{"http://localhost:3000/"}, false},
+		{"blank name", "  ", []string{ScopeVerify}, false, nil, true},
+		{"no scopes", "backend", nil, false, nil, true},
+		{"unknown scope", "backend", []string{"delete"}, false, nil, true},
+		{"publishable admin", "widget", []string{ScopeAdmin}, true, []string{"https://shop.example.com"}, true},
+		{"publishable identify", "widget", []string{ScopeVerify, ScopeIdentify}, true, []string{"https://shop.example.com"}, true},
+		{"publishable without origins", "widget", []string{ScopeVerify}, true, nil, true},
+		{"secret with origins", "backend", []string{ScopeVerify}, false, []string{"https://shop.example.com"}, true},
+		{"origin without scheme", "widget", []string{ScopeVerify}, true, []string{"shop.example.com"}, true},
+		{"origin with path", "widget", []string{ScopeVerify}, true, []string{"https://shop.example.com/checkout"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate(tt.keyName, tt.scopes, tt.publishable, tt.origins)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalid) {
+				t.Errorf("validate() error = %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
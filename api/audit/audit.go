@@ -0,0 +1,326 @@
+// Package audit keeps a tamper-evident log of the register and verification
+// attempts. The log is an append-only table in which every entry carries the
+// SHA-256 of its own content and of the entry before it, so that editing,
+// inserting or removing an entry breaks the chain from that point on.
+//
+// The chain cannot tell that entries were cut off its end: keep the head
+// hash printed by Verify somewhere else to detect it.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/db"
+)
+
+// Actions, named after the routes.
+const (
+	ActionRegister   = "register"
+	ActionVerify     = "verify"
+	ActionVerifyUser = "verify_user"
+)
+
+// Outcomes: a failure is an attempt rejected with a 4xx status, an error one
+// the server failed to handle.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeError   = "error"
+)
+
+// GenesisHash is the previous hash of the first entry.
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// Entry is an attempt. UserID is nil when the user is unknown, APIKeyID when
+// the request was not made with an API key. Reason is the error code of a
+// failed attempt.
+type Entry struct {
+	ID              int64     `json:"id"`
+	CreatedAt       time.Time `json:"created_at"`
+	TenantID        int       `json:"tenant_id"`
+	Action          string    `json:"action"`
+	Method          string    `json:"method"`
+	UserID          *int      `json:"user_id"`
+	Outcome         string    `json:"outcome"`
+	Reason          string    `json:"reason"`
+	Distance        *float64  `json:"distance"`
+	RectMotion      *float64  `json:"rect_motion"`
+	DescriptorShift *float64  `json:"descriptor_shift"`
+	ClientIP        string    `json:"client_ip"`
+	APIKeyID        *int      `json:"api_key_id"`
+	RequestID       string    `json:"request_id"`
+	DurationMS      int64     `json:"duration_ms"`
+	PrevHash        string    `json:"prev_hash"`
+	Hash            string    `json:"hash"`
+}
+
+// computeHash returns the hex SHA-256 of the JSON encoding of the entry with
+// an empty Hash. The encoding covers PrevHash, which chains the entries.
+func (e Entry) computeHash() (string, error) {
+	e.Hash = ""
+	body, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Record appends an entry to the log and returns it with its id, time and
+// hashes. Appends are serialized, by every instance of the server, so that
+// each entry chains to the one written before it.
+func Record(ctx context.Context, entry Entry) (Entry, error) {
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('audit_log'))`); err != nil {
+		return Entry{}, err
+	}
+
+	err = tx.QueryRowContext(ctx, `SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&entry.PrevHash)
+	if err == sql.ErrNoRows {
+		entry.PrevHash = GenesisHash
+	} else if err != nil {
+		return Entry{}, err
+	}
+	if err := tx.QueryRowContext(ctx, `SELECT nextval('audit_log_id_seq')`).Scan(&entry.ID); err != nil {
+		return Entry{}, err
+	}
+	// The time is hashed as stored, to the microsecond.
+	entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	if entry.Hash, err = entry.computeHash(); err != nil {
+		return Entry{}, err
+	}
+
+	query := `
+		INSERT INTO audit_log (
+			id,
+			created_at,
+			tenant_id,
+			action,
+			method,
+			user_id,
+			outcome,
+			reason,
+			distance,
+			rect_motion,
+			descriptor_shift,
+			client_ip,
+			api_key_id,
+			request_id,
+			duration_ms,
+			prev_hash,
+			hash
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`
+	_, err = tx.ExecContext(ctx, query,
+		entry.ID,
+		entry.CreatedAt,
+		entry.TenantID,
+		entry.Action,
+		entry.Method,
+		entry.UserID,
+		entry.Outcome,
+		entry.Reason,
+		entry.Distance,
+		entry.RectMotion,
+		entry.DescriptorShift,
+		entry.ClientIP,
+		entry.APIKeyID,
+		entry.RequestID,
+		entry.DurationMS,
+		entry.PrevHash,
+		entry.Hash,
+	)
+	if err != nil {
+		return Entry{}, err
+	}
+	return entry, tx.Commit()
+}
+
+// Range selects entries. Zero fields do not restrict the selection; Until
+// is exclusive.
+type Range struct {
+	FromID   int64
+	ToID     int64
+	Since    time.Time
+	Until    time.Time
+	TenantID int
+}
+
+// each calls fn with the entries of rng in chain order.
+func each(ctx context.Context, rng Range, fn func(Entry) error) error {
+	query := `
+		SELECT
+			id,
+			created_at,
+			tenant_id,
+			action,
+			method,
+			user_id,
+			outcome,
+			reason,
+			distance,
+			rect_motion,
+			descriptor_shift,
+			client_ip,
+			api_key_id,
+			request_id,
+			duration_ms,
+			prev_hash,
+			hash
+		FROM audit_log
+		WHERE ($1::bigint = 0 OR id >= $1)
+			AND ($2::bigint = 0 OR id <= $2)
+			AND ($3::timestamptz IS NULL OR created_at >= $3)
+			AND ($4::timestamptz IS NULL OR created_at < $4)
+			AND ($5 = 0 OR tenant_id = $5)
+		ORDER BY id`
+	since := sql.NullTime{Time: rng.Since, Valid: !rng.Since.IsZero()}
+	until := sql.NullTime{Time: rng.Until, Valid: !rng.Until.IsZero()}
+	rows, err := db.DB.QueryContext(ctx, query, rng.FromID, rng.ToID, since, until, rng.TenantID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry Entry
+		var userID, apiKeyID sql.NullInt64
+		var distance, rectMotion, descriptorShift sql.NullFloat64
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.CreatedAt,
+			&entry.TenantID,
+			&entry.Action,
+			&entry.Method,
+			&userID,
+			&entry.Outcome,
+			&entry.Reason,
+			&distance,
+			&rectMotion,
+			&descriptorShift,
+			&entry.ClientIP,
+			&apiKeyID,
+			&entry.RequestID,
+			&entry.DurationMS,
+			&entry.PrevHash,
+			&entry.Hash,
+		); err != nil {
+			return err
+		}
+		entry.CreatedAt = entry.CreatedAt.UTC()
+		entry.UserID = nullInt(userID)
+		entry.APIKeyID = nullInt(apiKeyID)
+		entry.Distance = nullFloat(distance)
+		entry.RectMotion = nullFloat(rectMotion)
+		entry.DescriptorShift = nullFloat(descriptorShift)
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func nullInt(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	v := int(value.Int64)
+	return &v
+}
+
+func nullFloat(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
+
+// ChainError reports the first entry that does not chain to the one before
+// it or whose content does not match its hash.
+type ChainError struct {
+	ID     int64
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit: entry %d: %s", e.ID, e.Reason)
+}
+
+// Summary describes a verified chain.
+type Summary struct {
+	Entries int64
+	FirstID int64
+	LastID  int64
+	// Head is the hash of the last entry.
+	Head string
+}
+
+// Verify checks the chain between the ids fromID and toID, or to the end
+// when toID is 0. A chain checked from its start must begin at the genesis
+// hash; one checked from fromID trusts the previous hash of its first entry.
+// It returns a *ChainError when the chain is broken.
+func Verify(ctx context.Context, fromID, toID int64) (Summary, error) {
+	var c chain
+	if fromID == 0 {
+		c.prev = GenesisHash
+	}
+	err := each(ctx, Range{FromID: fromID, ToID: toID}, c.add)
+	return c.summary, err
+}
+
+// chain checks entries in id order. prev is the hash the next entry must
+// chain to, any hash when empty.
+type chain struct {
+	prev    string
+	summary Summary
+}
+
+func (c *chain) add(entry Entry) error {
+	if c.prev != "" && entry.PrevHash != c.prev {
+		return &ChainError{ID: entry.ID, Reason: "previous hash does not match the entry before it"}
+	}
+	hash, err := entry.computeHash()
+	if err != nil {
+		return &ChainError{ID: entry.ID, Reason: err.Error()}
+	}
+	if hash != entry.Hash {
+		return &ChainError{ID: entry.ID, Reason: "content does not match its hash"}
+	}
+
+	if c.summary.Entries == 0 {
+		c.summary.FirstID = entry.ID
+	}
+	c.summary.Entries++
+	c.summary.LastID = entry.ID
+	c.summary.Head = entry.Hash
+	c.prev = entry.Hash
+	return nil
+}
+
+// Export writes the entries of rng to w as JSON lines, hashes included, so
+// that the export can be checked on its own. It returns the number of
+// entries written.
+func Export(ctx context.Context, w io.Writer, rng Range) (int64, error) {
+	var written int64
+	encoder := json.NewEncoder(w)
+	err := each(ctx, rng, func(entry Entry) error {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+		written++
+		return nil
+	})
+	return written, err
+}
@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// linked returns n entries chained from the genesis hash the way Record
+// writes them.
+func linked(t *testing.T, n int) []Entry {
+	t.Helper()
+	distance := 0.08
+	entries := make([]Entry, n)
+	prev := GenesisHash
+	for i := range entries {
+		entry := Entry{
+			ID:        int64(i + 1),
+			CreatedAt: time.Date(2024, 5, 1, 12, 0, i, 0, time.UTC),
+			TenantID:  1,
+			Action:    "verify",
+			Method:    "single_image",
+			Outcome:   "success",
+			Distance:  &distance,
+			ClientIP:  "203.0.113.1",
+			PrevHash:  prev,
+		}
+		hash, err := entry.computeHash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.Hash = hash
+		entries[i] = entry
+		prev = hash
+	}
+	return entries
+}
+
+func TestComputeHash(t *testing.T) {
+	entry := linked(t, 1)[0]
+	hash, err := entry.computeHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != entry.Hash {
+		t.Errorf("computeHash() is not stable: %s, then %s", entry.Hash, hash)
+	}
+	if len(hash) != len(GenesisHash) {
+		t.Errorf("computeHash() = %q, want %d hex digits", hash, len(GenesisHash))
+	}
+
+	entry.Hash = "ignored"
+	if hash, _ := entry.computeHash(); hash != linked(t, 1)[0].Hash {
+		t.Error("computeHash() depends on Hash")
+	}
+
+	changes := map[string]func(*Entry){
+		"prev_hash": func(e *Entry) { e.PrevHash = e.Hash },
+		"outcome":   func(e *Entry) { e.Outcome = "failure" },
+		"distance":  func(e *Entry) { e.Distance = nil },
+		"time":      func(e *Entry) { e.CreatedAt = e.CreatedAt.Add(time.Microsecond) },
+	}
+	for field, change := range changes {
+		changed := linked(t, 1)[0]
+		change(&changed)
+		if hash, _ := changed.computeHash(); hash == changed.Hash {
+			t.Errorf("computeHash() does not cover %s", field)
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	tests := []struct {
+		name     string
+		prev     string
+		entries  func([]Entry) []Entry
+		brokenAt int64 // 0 when the chain holds
+	}{
+		{"intact", GenesisHash, func(e []Entry) []Entry { return e }, 0},
+		{"checked from an id", "", func(e []Entry) []Entry { return e[2:] }, 0},
+		{"not from the genesis", GenesisHash, func(e []Entry) []Entry { return e[1:] }, 2},
+		{"entry removed", GenesisHash, func(e []Entry) []Entry { return append(e[:2:2], e[3:]...) }, 4},
+		{"entries swapped", GenesisHash, func(e []Entry) []Entry {
+			e[1], e[2] = e[2], e[1]
+			return e
+		}, 3},
+		{"entry altered", GenesisHash, func(e []Entry) []Entry {
+			e[3].Outcome = "success, really"
+			return e
+		}, 4},
+		{"entry altered and rehashed", GenesisHash, func(e []Entry) []Entry {
+			e[1].Outcome = "failure"
+			e[1].Hash, _ = e[1].computeHash()
+			return e
+		}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := tt.entries(linked(t, 5))
+			c := chain{prev: tt.prev}
+			var err error
+			for _, entry := range entries {
+				if err = c.add(entry); err != nil {
+					break
+				}
+			}
+
+			if tt.brokenAt == 0 {
+				if err != nil {
+					t.Fatalf("add() error = %v", err)
+				}
+				last := entries[len(entries)-1]
+				want := Summary{Entries: int64(len(entries)), FirstID: entries[0].ID, LastID: last.ID, Head: last.Hash}
+				if c.summary != want {
+					t.Errorf("summary = %+v, want %+v", c.summary, want)
+				}
+				return
+			}
+
+			var chainErr *ChainError
+			if !errors.As(err, &chainErr) {
+				t.Fatalf("add() error = %v, want a *ChainError", err)
+			}
+			if chainErr.ID != tt.brokenAt {
+				t.Errorf("broken at entry %d, want %d", chainErr.ID, tt.brokenAt)
+			}
+		})
+	}
+}
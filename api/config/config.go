@@ -0,0 +1,297 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/creasty/defaults"
+)
+
+// Config holds the deployment settings. Every field can be overridden by the
+// environment variable named in its env tag.
+type Config struct {
+	Server     ServerConfig
+	Enrollment EnrollmentConfig
+	Liveness   LivenessConfig
+	Faces      FacesConfig
+	Admin      AdminConfig
+	APIKeys    APIKeysConfig
+	Token      TokenConfig
+	OIDC       OIDCConfig
+	RateLimit  RateLimitConfig
+	Lockout    LockoutConfig
+	Health     HealthConfig
+	Metrics    MetricsConfig
+	Log        LogConfig
+	Tracing    TracingConfig
+	Webhooks   WebhookConfig
+	Storage    StorageConfig
+}
+
+type ServerConfig struct {
+	Port              string        `env:"PORT" default:"8080"`
+	ReadHeaderTimeout time.Duration `env:"SERVER_READ_HEADER_TIMEOUT" default:"10s"`
+	ReadTimeout       time.Duration `env:"SERVER_READ_TIMEOUT" default:"60s"`
+	// WriteTimeout covers the whole handler, recognition included.
+	WriteTimeout time.Duration `env:"SERVER_WRITE_TIMEOUT" default:"120s"`
+	IdleTimeout  time.Duration `env:"SERVER_IDLE_TIMEOUT" default:"120s"`
+	// ShutdownTimeout is how long in-flight requests get to finish once the
+	// server is asked to stop.
+	ShutdownTimeout time.Duration `env:"SERVER_SHUTDOWN_TIMEOUT" default:"30s"`
+	// MaxBodyBytes bounds request bodies. Routes taking frame sequences are
+	// bounded by MaxFramesBodyBytes instead.
+	MaxBodyBytes       int64 `env:"MAX_BODY_BYTES" default:"16777216"`
+	MaxFramesBodyBytes int64 `env:"MAX_FRAMES_BODY_BYTES" default:"67108864"`
+}
+
+type EnrollmentConfig struct {
+	RequireFrontalPose bool    `env:"ENROLLMENT_REQUIRE_FRONTAL_POSE" default:"false"`
+	MaxYaw             float64 `env:"ENROLLMENT_MAX_YAW" default:"15"`
+	MaxPitch           float64 `env:"ENROLLMENT_MAX_PITCH" default:"15"`
+	MaxRoll            float64 `env:"ENROLLMENT_MAX_ROLL" default:"10"`
+	MinTurnYaw         float64 `env:"ENROLLMENT_MIN_TURN_YAW" default:"8"`
+	MaxTurnYaw         float64 `env:"ENROLLMENT_MAX_TURN_YAW" default:"35"`
+}
+
+// LivenessConfig holds the default liveness policy, which tenants may
+// override.
+type LivenessConfig struct {
+	FrameCount            int     `env:"LIVENESS_FRAME_COUNT" default:"5"`
+	MaxRectMotion         float64 `env:"LIVENESS_MAX_RECT_MOTION" default:"10"`
+	MinDescriptorShift    float64 `env:"LIVENESS_MIN_DESCRIPTOR_SHIFT" default:"0.07"`
+	RequireAtRegistration bool    `env:"REGISTRATION_REQUIRE_LIVENESS" default:"false"`
+}
+
+type FacesConfig struct {
+	// MultiFacePolicy is one of reject, largest or central.
+	MultiFacePolicy string `env:"MULTI_FACE_POLICY" default:"reject"`
+	// MatchThreshold is the squared descriptor distance within which two
+	// faces are the same person. Tenants may override it.
+	MatchThreshold float64 `env:"MATCH_THRESHOLD" default:"0.12"`
+}
+
+type AdminConfig struct {
+	// Token grants every scope when sent as a bearer token, which is how the
+	// first API keys get created. It is disabled while empty.
+	Token string `env:"ADMIN_TOKEN"`
+}
+
+type APIKeysConfig struct {
+	// Required makes every API route demand an API key. Turning it off lets
+	// keyless requests through while clients are being migrated.
+	Required bool `env:"API_KEYS_REQUIRED" default:"true"`
+}
+
+// TokenConfig configures the signed tokens issued on verification.
+type TokenConfig struct {
+	Issuer   string        `env:"TOKEN_ISSUER" default:"face-widget"`
+	Audience string        `env:"TOKEN_AUDIENCE"`
+	TTL      time.Duration `env:"TOKEN_TTL" default:"5m"`
+	// KeysDir holds the ES256 signing keys as <kid>.pem files. An ephemeral
+	// key is used when it is empty.
+	KeysDir      string `env:"TOKEN_KEYS_DIR"`
+	SigningKeyID string `env:"TOKEN_SIGNING_KEY_ID"`
+}
+
+type OIDCConfig struct {
+	// Issuer is the public base URL of the server, such as
+	// https://faces.example.com. The OpenID Connect provider is disabled
+	// while it is empty.
+	Issuer     string        `env:"OIDC_ISSUER"`
+	RequestTTL time.Duration `env:"OIDC_REQUEST_TTL" default:"10m"`
+	CodeTTL    time.Duration `env:"OIDC_CODE_TTL" default:"1m"`
+	TokenTTL   time.Duration `env:"OIDC_TOKEN_TTL" default:"1h"`
+	// WidgetKey is the publishable key with the verify scope the sign-in
+	// page calls the API with for clients that have no key of their own. Its
+	// allowed origins must include the issuer.
+	WidgetKey string `env:"OIDC_WIDGET_KEY"`
+}
+
+// RateLimitConfig limits failed verifications within a sliding window. A
+// limit of 0 disables it.
+type RateLimitConfig struct {
+	// Store is memory, for a single instance, or postgres, which replicas
+	// share.
+	Store     string        `env:"RATE_LIMIT_STORE" default:"memory"`
+	Window    time.Duration `env:"RATE_LIMIT_WINDOW" default:"15m"`
+	PerUser   int           `env:"RATE_LIMIT_PER_USER" default:"10"`
+	PerIP     int           `env:"RATE_LIMIT_PER_IP" default:"20"`
+	PerAPIKey int           `env:"RATE_LIMIT_PER_API_KEY" default:"200"`
+	// TrustProxy takes the client IP from the last X-Forwarded-For entry,
+	// which only a reverse proxy in front of the server can vouch for.
+	TrustProxy bool `env:"TRUST_PROXY" default:"false"`
+}
+
+// LockoutConfig locks an account after Threshold consecutive failed
+// verifications, for Cooldown at first and twice as long every time it
+// happens again, up to MaxCooldown. A threshold of 0 disables it.
+type LockoutConfig struct {
+	Threshold   int           `env:"LOCKOUT_THRESHOLD" default:"5"`
+	Cooldown    time.Duration `env:"LOCKOUT_COOLDOWN" default:"1m"`
+	MaxCooldown time.Duration `env:"LOCKOUT_MAX_COOLDOWN" default:"1h"`
+}
+
+type HealthConfig struct {
+	// Timeout bounds every readiness check.
+	Timeout time.Duration `env:"HEALTH_TIMEOUT" default:"5s"`
+	// SelfTestImage is a JPEG with exactly one face the readiness probe
+	// runs the recognizer on. Like the models, it is supplied with the
+	// deployment, and the probe fails while it is missing.
+	SelfTestImage    string        `env:"HEALTH_SELF_TEST_IMAGE" default:"models/selftest.jpg"`
+	SelfTestInterval time.Duration `env:"HEALTH_SELF_TEST_INTERVAL" default:"5m"`
+}
+
+type MetricsConfig struct {
+	// Token, when set, must be sent as a bearer token to read /metrics.
+	Token string `env:"METRICS_TOKEN"`
+}
+
+type LogConfig struct {
+	// Level is debug, info, warn or error.
+	Level string `env:"LOG_LEVEL" default:"info"`
+	// Format is json or text.
+	Format string `env:"LOG_FORMAT" default:"json"`
+}
+
+// TracingConfig reads the standard OpenTelemetry variables.
+type TracingConfig struct {
+	// Exporter is otlp, console, which writes spans to stdout, or none.
+	Exporter string `env:"OTEL_TRACES_EXPORTER" default:"none"`
+	// Endpoint is the base URL of the OTLP/HTTP collector.
+	Endpoint string `env:"OTEL_EXPORTER_OTLP_ENDPOINT" default:"http://localhost:4318"`
+	// Headers are comma separated key=value pairs sent to the collector.
+	Headers     string  `env:"OTEL_EXPORTER_OTLP_HEADERS"`
+	ServiceName string  `env:"OTEL_SERVICE_NAME" default:"face-widget"`
+	SampleRatio float64 `env:"OTEL_TRACES_SAMPLER_ARG" default:"1"`
+}
+
+// WebhookConfig tunes the delivery of webhooks. A failed delivery is retried
+// after Backoff, then twice as long every time, up to MaxBackoff, until
+// MaxAttempts have been made.
+type WebhookConfig struct {
+	PollInterval time.Duration `env:"WEBHOOK_POLL_INTERVAL" default:"5s"`
+	Timeout      time.Duration `env:"WEBHOOK_TIMEOUT" default:"10s"`
+	MaxAttempts  int           `env:"WEBHOOK_MAX_ATTEMPTS" default:"10"`
+	Backoff      time.Duration `env:"WEBHOOK_BACKOFF" default:"30s"`
+	MaxBackoff   time.Duration `env:"WEBHOOK_MAX_BACKOFF" default:"6h"`
+}
+
+// StorageConfig picks where the face images are kept. The cloudinary backend
+// reads its credentials from CLOUDINARY_URL.
+type StorageConfig struct {
+	// Backend is cloudinary, local or s3.
+	Backend string `env:"STORAGE_BACKEND" default:"cloudinary"`
+	// LocalDir is the directory of the local backend.
+	LocalDir string `env:"STORAGE_LOCAL_DIR" default:"storage"`
+	// LocalURL is the public URL of the /storage/ route serving the local
+	// backend, which its signed URLs point to.
+	LocalURL string `env:"STORAGE_LOCAL_URL" default:"http://localhost:8080/storage"`
+	// SigningKey signs the URLs of the local backend. An ephemeral key is
+	// used when it is empty, and URLs then stop working on restart.
+	SigningKey string `env:"STORAGE_SIGNING_KEY"`
+	// S3Endpoint is the base URL of the S3-compatible service, such as
+	// https://s3.us-east-1.amazonaws.com or http://localhost:9000 for MinIO.
+	S3Endpoint        string `env:"S3_ENDPOINT" default:"https://s3.amazonaws.com"`
+	S3Region          string `env:"S3_REGION" default:"us-east-1"`
+	S3Bucket          string `env:"S3_BUCKET"`
+	S3AccessKeyID     string `env:"S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey string `env:"S3_SECRET_ACCESS_KEY"`
+	// S3PathStyle puts the bucket in the path rather than in the host name,
+	// as MinIO expects.
+	S3PathStyle bool `env:"S3_PATH_STYLE" default:"false"`
+}
+
+var Cfg Config
+
+func Load() {
+	if err := defaults.Set(&Cfg); err != nil {
+		log.Fatalf("config: failed to set defaults: %v", err)
+	}
+
+	if err := loadEnv(reflect.ValueOf(&Cfg).Elem()); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
+	if err := Cfg.validate(); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+}
+
+// validate rejects the values the server cannot run with.
+func (c *Config) validate() error {
+	// Liveness measures the motion between consecutive frames.
+	if c.Liveness.FrameCount < 2 {
+		return fmt.Errorf("invalid value for LIVENESS_FRAME_COUNT: %d, expected at least 2", c.Liveness.FrameCount)
+	}
+	return nil
+}
+
+func loadEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		sf := t.Field(i)
+
+		if field.Kind() == reflect.Struct {
+			if err := loadEnv(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := sf.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(name)
+		if !ok || raw == "" {
+			continue
+		}
+
+		if err := setField(field, raw); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, raw string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field kind %s", field.Kind())
+	}
+	return nil
+}
@@ -3,10 +3,12 @@ package db
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 
-	_ "github.com/lib/pq"
+	"github.com/Adedunmol/face-widget/logging"
+	"github.com/Adedunmol/face-widget/tracing"
+	"github.com/lib/pq"
 )
 
 var DB *sql.DB
@@ -14,19 +16,19 @@ var DB *sql.DB
 func ConnectDB() error {
 	connStr := os.Getenv("DB_CONNECTION_STRING")
 	if connStr == "" {
-		log.Fatal("Error: DB_CONNECTION_STRING environment variable not set.")
+		logging.Fatal("DB_CONNECTION_STRING environment variable not set")
 	}
-	var err error
-	DB, err = sql.Open("postgres", connStr)
+	connector, err := pq.NewConnector(connStr)
 	if err != nil {
 		return fmt.Errorf("failed to open database connection: %w", err)
 	}
+	DB = sql.OpenDB(tracing.WrapConnector(connector, "postgresql"))
 
 	err = DB.Ping()
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	fmt.Println("Successfully connected to PostgreSQL!")
+	slog.Info("Connected to PostgreSQL")
 	return nil
 }
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE face_samples (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	pose VARCHAR(20) NOT NULL,
+	image_url VARCHAR(255) NOT NULL,
+	descriptor REAL[] NOT NULL,
+	quality REAL NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX face_samples_user_id_idx ON face_samples (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS face_samples;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE oidc_clients (
+	client_id VARCHAR(64) PRIMARY KEY,
+	secret_hash CHAR(64) NOT NULL,
+	name VARCHAR(100) NOT NULL,
+	redirect_uris TEXT[] NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- An authorization starts as a pending request when /authorize is opened and
+-- gains a user and a code once the face verification succeeds. Exchanging the
+-- code deletes it.
+CREATE TABLE oidc_authorizations (
+	id VARCHAR(64) PRIMARY KEY,
+	client_id VARCHAR(64) NOT NULL REFERENCES oidc_clients(client_id) ON DELETE CASCADE,
+	redirect_uri TEXT NOT NULL,
+	scope TEXT NOT NULL,
+	state TEXT NOT NULL,
+	nonce TEXT NOT NULL,
+	code_challenge TEXT NOT NULL,
+	user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+	code_hash CHAR(64) UNIQUE,
+	auth_time TIMESTAMPTZ,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS oidc_authorizations;
+DROP TABLE IF EXISTS oidc_clients;
+-- +goose StatementEnd
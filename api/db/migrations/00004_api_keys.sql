@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE api_keys (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	prefix VARCHAR(16) NOT NULL,
+	key_hash CHAR(64) UNIQUE NOT NULL,
+	publishable BOOLEAN NOT NULL DEFAULT FALSE,
+	scopes TEXT[] NOT NULL,
+	allowed_origins TEXT[] NOT NULL DEFAULT '{}',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	revoked_at TIMESTAMPTZ
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS api_keys;
+-- +goose StatementEnd
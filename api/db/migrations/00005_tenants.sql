@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE tenants (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) UNIQUE NOT NULL,
+	settings JSONB NOT NULL DEFAULT '{}',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Everything that exists so far belongs to the default tenant.
+INSERT INTO tenants (id, name) VALUES (1, 'default');
+SELECT setval('tenants_id_seq', 1);
+
+ALTER TABLE users ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id) ON DELETE CASCADE;
+ALTER TABLE users ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+ALTER TABLE users ADD CONSTRAINT users_tenant_id_email_key UNIQUE (tenant_id, email);
+
+ALTER TABLE face_samples ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id) ON DELETE CASCADE;
+ALTER TABLE face_samples ALTER COLUMN tenant_id DROP DEFAULT;
+CREATE INDEX face_samples_tenant_id_idx ON face_samples (tenant_id);
+
+ALTER TABLE api_keys ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id) ON DELETE CASCADE;
+ALTER TABLE api_keys ALTER COLUMN tenant_id DROP DEFAULT;
+
+-- widget_key is the publishable key the sign-in page of the client verifies
+-- faces with. Publishable keys are public, it is kept in clear.
+ALTER TABLE oidc_clients ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id) ON DELETE CASCADE;
+ALTER TABLE oidc_clients ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE oidc_clients ADD COLUMN widget_key TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE oidc_clients DROP COLUMN widget_key;
+ALTER TABLE oidc_clients DROP COLUMN tenant_id;
+ALTER TABLE api_keys DROP COLUMN tenant_id;
+ALTER TABLE face_samples DROP COLUMN tenant_id;
+ALTER TABLE users DROP CONSTRAINT users_tenant_id_email_key;
+ALTER TABLE users DROP COLUMN tenant_id;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+DROP TABLE IF EXISTS tenants;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Keys are user:<id>, ip:<address> or key:<api key id>.
+CREATE TABLE rate_limit_failures (
+	key TEXT NOT NULL,
+	failed_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX rate_limit_failures_key_failed_at_idx ON rate_limit_failures (key, failed_at);
+
+CREATE TABLE account_lockouts (
+	key TEXT PRIMARY KEY,
+	failures INTEGER NOT NULL DEFAULT 0,
+	lockouts INTEGER NOT NULL DEFAULT 0,
+	locked_until TIMESTAMPTZ
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS account_lockouts;
+DROP TABLE IF EXISTS rate_limit_failures;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The secret signs the deliveries, so it has to be kept in clear. An empty
+-- events array subscribes the endpoint to every event.
+CREATE TABLE webhook_endpoints (
+	id SERIAL PRIMARY KEY,
+	tenant_id INTEGER NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	events TEXT[] NOT NULL DEFAULT '{}',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX webhook_endpoints_tenant_id_idx ON webhook_endpoints (tenant_id);
+
+-- The outbox: one row per event and endpoint. status is pending, delivered
+-- or failed, once the attempts are exhausted.
+CREATE TABLE webhook_deliveries (
+	id BIGSERIAL PRIMARY KEY,
+	endpoint_id INTEGER NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+	event_id UUID NOT NULL,
+	event_type TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	last_status_code INTEGER,
+	last_error TEXT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_endpoint_id_idx ON webhook_deliveries (endpoint_id, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_endpoints;
+-- +goose StatementEnd
@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+-- One row per register and verification attempt. hash is the SHA-256 of the
+-- row's content, prev_hash included, which chains every row to the one
+-- before: editing or removing a row breaks the chain. There are no foreign
+-- keys, as the rows outlive the users and keys they mention.
+CREATE TABLE audit_log (
+	id BIGINT PRIMARY KEY,
+	created_at TIMESTAMPTZ NOT NULL,
+	tenant_id INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	method TEXT NOT NULL DEFAULT '',
+	user_id INTEGER,
+	outcome TEXT NOT NULL,
+	reason TEXT NOT NULL DEFAULT '',
+	distance DOUBLE PRECISION,
+	rect_motion DOUBLE PRECISION,
+	descriptor_shift DOUBLE PRECISION,
+	client_ip TEXT NOT NULL DEFAULT '',
+	api_key_id INTEGER,
+	request_id TEXT NOT NULL DEFAULT '',
+	duration_ms BIGINT NOT NULL,
+	prev_hash TEXT NOT NULL,
+	hash TEXT NOT NULL
+);
+
+CREATE SEQUENCE audit_log_id_seq OWNED BY audit_log.id;
+
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
+CREATE INDEX audit_log_tenant_id_idx ON audit_log (tenant_id, id);
+
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_no_update_delete
+	BEFORE UPDATE OR DELETE ON audit_log
+	FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER audit_log_no_truncate
+	BEFORE TRUNCATE ON audit_log
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_log;
+DROP FUNCTION IF EXISTS audit_log_append_only();
+-- +goose StatementEnd
@@ -1,8 +1,9 @@
 package db
 
 import (
-	"log"
+	"log/slog"
 
+	"github.com/Adedunmol/face-widget/logging"
 	"github.com/pressly/goose/v3"
 )
 
@@ -13,8 +14,8 @@ func RunMigrations() {
 
 	// Run the migrations
 	if err := goose.Up(DB, "./api/db/migrations"); err != nil {
-		log.Fatalf("goose: failed to run migrations: %v\n", err)
+		logging.Fatal("Failed to run migrations", "error", err)
 	}
 
-	log.Println("Database migrations applied successfully.")
+	slog.Info("Database migrations applied")
 }
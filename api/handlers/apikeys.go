@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Adedunmol/face-widget/api/apikeys"
+	"github.com/Adedunmol/face-widget/api/models"
+)
+
+func apiKeyModel(key apikeys.Key) models.APIKey {
+	return models.APIKey{
+		ID:             key.ID,
+		Name:           key.Name,
+		Prefix:         key.Prefix,
+		Publishable:    key.Publishable,
+		Scopes:         key.Scopes,
+		AllowedOrigins: key.AllowedOrigins,
+		CreatedAt:      key.CreatedAt,
+		RevokedAt:      key.RevokedAt,
+	}
+}
+
+func ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	keys, err := apikeys.List(r.Context(), tenantOf(r).ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to list API keys", "error", err)
+		respondWithError(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]models.APIKey, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, apiKeyModel(key))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// CreateAPIKey returns the new key in clear. It is the only time it is shown.
+func CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var thisRequest models.CreateAPIKeyPayload
+	if err := readJSON(r, &thisRequest); err != nil {
+		respondWithPayloadError(w, err)
+		return
+	}
+
+	key, raw, err := apikeys.Create(
+		r.Context(),
+		tenantOf(r).ID,
+		thisRequest.Name,
+		thisRequest.Scopes,
+		thisRequest.Publishable,
+		thisRequest.AllowedOrigins,
+	)
+	if errors.Is(err, apikeys.ErrInvalid) {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to create API key", "error", err)
+		respondWithError(w, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, models.CreatedAPIKey{APIKey: apiKeyModel(key), Key: raw})
+}
+
+func RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err == nil {
+		err = apikeys.Revoke(r.Context(), tenantOf(r).ID, id)
+	}
+	if err != nil {
+		if _, ok := err.(*strconv.NumError); ok || err == apikeys.ErrNotFound {
+			respondWithCode(w, CodeAPIKeyNotFound, "API key not found", http.StatusNotFound, nil)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to revoke API key", "error", err)
+		respondWithError(w, "Failed to revoke API key", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/audit"
+	"github.com/Adedunmol/face-widget/api/webhooks"
+	"github.com/Adedunmol/face-widget/logging"
+)
+
+// auditWriter remembers the status and error code an audited attempt was
+// answered with. writeError fills in the code.
+type auditWriter struct {
+	http.ResponseWriter
+	status int
+	code   string
+}
+
+func (w *auditWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// auditTrail collects what an attempt learns about itself until it is
+// answered.
+type auditTrail struct {
+	entry  audit.Entry
+	start  time.Time
+	writer *auditWriter
+}
+
+// startAudit starts auditing an attempt. The handler must answer through
+// the returned writer and call record once done, typically deferred.
+func startAudit(w http.ResponseWriter, r *http.Request, action, method string) (http.ResponseWriter, *auditTrail) {
+	attempt := verificationAttempt(r, 0)
+	trail := &auditTrail{
+		entry: audit.Entry{
+			TenantID:  tenantOf(r).ID,
+			Action:    action,
+			Method:    method,
+			ClientIP:  attempt.IP,
+			RequestID: logging.RequestID(r.Context()),
+		},
+		start:  time.Now(),
+		writer: &auditWriter{ResponseWriter: w},
+	}
+	if attempt.APIKey != 0 {
+		trail.entry.APIKeyID = &attempt.APIKey
+	}
+	return trail.writer, trail
+}
+
+func (t *auditTrail) setUser(userID int) {
+	if userID != 0 {
+		t.entry.UserID = &userID
+	}
+}
+
+// observe keeps the user, reason and scores of a verification event.
+func (t *auditTrail) observe(data webhooks.VerificationData) {
+	t.setUser(data.UserID)
+	t.entry.Reason = data.Reason
+	t.entry.Distance = data.Distance
+	t.entry.RectMotion = data.RectMotion
+	t.entry.DescriptorShift = data.DescriptorShift
+}
+
+// record writes the audit entry of the answered attempt. The reason of a
+// failure defaults to the error code of the response. Failing to write the
+// entry does not fail the request.
+func (t *auditTrail) record(r *http.Request) {
+	entry := t.entry
+	entry.DurationMS = time.Since(t.start).Milliseconds()
+
+	// Nothing was written when the handler panicked.
+	status := t.writer.status
+	switch {
+	case status == 0:
+		entry.Outcome = audit.OutcomeError
+		entry.Reason = CodeInternal
+	case status < http.StatusBadRequest:
+		entry.Outcome = audit.OutcomeSuccess
+		entry.Reason = ""
+	case status < http.StatusInternalServerError:
+		entry.Outcome = audit.OutcomeFailure
+	default:
+		entry.Outcome = audit.OutcomeError
+	}
+	if entry.Reason == "" && entry.Outcome != audit.OutcomeSuccess {
+		entry.Reason = t.writer.code
+	}
+
+	ctx := context.WithoutCancel(r.Context())
+	if _, err := audit.Record(ctx, entry); err != nil {
+		slog.ErrorContext(ctx, "Failed to record audit entry", "action", entry.Action, "error", err)
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Adedunmol/face-widget/api/apikeys"
+	"github.com/Adedunmol/face-widget/api/config"
+	"github.com/Adedunmol/face-widget/api/tenants"
+)
+
+// TenantHeader selects the tenant a request made with the admin token acts
+// on. API keys always act on the tenant they belong to.
+const TenantHeader = "X-Tenant-ID"
+
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
+
+// isAdminToken reports whether the request carries the configured admin
+// token.
+func isAdminToken(r *http.Request) bool {
+	token := config.Cfg.Admin.Token
+	if token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(token)) == 1
+}
+
+// isAdmin reports whether the request carries the admin token or an API key
+// with the admin scope.
+func isAdmin(r *http.Request) bool {
+	if key := apikeys.FromContext(r.Context()); key != nil && key.HasScope(apikeys.ScopeAdmin) {
+		return true
+	}
+	return isAdminToken(r)
+}
+
+// tenantOf returns the tenant the request was resolved to by RequireScope.
+func tenantOf(r *http.Request) *tenants.Tenant {
+	if tenant := tenants.FromContext(r.Context()); tenant != nil {
+		return tenant
+	}
+	return &tenants.Tenant{ID: tenants.DefaultID}
+}
+
+// withTenant loads the tenant id and serves the request with it in the
+// context.
+func withTenant(w http.ResponseWriter, r *http.Request, id int, next http.Handler) {
+	tenant, err := tenants.Load(r.Context(), id)
+	if err == tenants.ErrNotFound {
+		respondWithError(w, "Unknown tenant", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to load tenant", "error", err)
+		respondWithError(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+	next.ServeHTTP(w, r.WithContext(tenants.NewContext(r.Context(), tenant)))
+}
+
+// RequireScope only lets requests through that carry an API key granting
+// scope, or the admin token, which grants every scope. Publishable keys are
+// further limited to their allowed origins. The request then runs for the
+// tenant of the key, or the one named by TenantHeader for the admin token.
+func RequireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isAdminToken(r) {
+			id := tenants.DefaultID
+			if header := r.Header.Get(TenantHeader); header != "" {
+				parsed, err := strconv.Atoi(header)
+				if err != nil {
+					respondWithError(w, "Invalid "+TenantHeader+" header", http.StatusBadRequest)
+					return
+				}
+				id = parsed
+			}
+			withTenant(w, r, id, next)
+			return
+		}
+
+		raw := bearerToken(r)
+		if raw == "" {
+			if !config.Cfg.APIKeys.Required {
+				withTenant(w, r, tenants.DefaultID, next)
+				return
+			}
+			respondWithCode(w, CodeInvalidCredentials, "API key required", http.StatusUnauthorized, nil)
+			return
+		}
+
+		key, err := apikeys.Lookup(r.Context(), raw)
+		if err == apikeys.ErrUnknownKey {
+			respondWithCode(w, CodeInvalidCredentials, "Invalid API key", http.StatusUnauthorized, nil)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to look up API key", "error", err)
+			respondWithError(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		if !key.AllowsOrigin(r.Header.Get("Origin")) {
+			respondWithCode(w, CodeOriginNotAllowed, "Origin not allowed for this key", http.StatusForbidden, nil)
+			return
+		}
+		if !key.HasScope(scope) {
+			respondWithCode(w, CodeInsufficientScope, "API key lacks the "+scope+" scope", http.StatusForbidden,
+				map[string]interface{}{"scope": scope})
+			return
+		}
+
+		withTenant(w, r.WithContext(apikeys.NewContext(r.Context(), key)), key.TenantID, next)
+	})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"bytes"
+	"image"
+	"log/slog"
+	"net/http"
+
+	"github.com/Adedunmol/face-widget/api/models"
+	"github.com/Adedunmol/face-widget/core"
+)
+
+// CompareFaces matches the face on an identity document against a selfie
+// without creating an account. Neither image is stored.
+func CompareFaces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var documentData, selfieData []byte
+	if isMultipart(r) {
+		form, err := readMultipart(r)
+		if err != nil {
+			respondWithPayloadError(w, multipartError(err))
+			return
+		}
+		documentData = form.File("document_image")
+		selfieData = form.File("selfie_image")
+	} else {
+		var thisRequest models.ComparePayload
+		if err := readJSON(r, &thisRequest); err != nil {
+			respondWithPayloadError(w, err)
+			return
+		}
+
+		var err error
+		documentData, err = decodeImage(thisRequest.DocumentImage)
+		if err != nil {
+			respondWithError(w, "Invalid Base64 string for document_image", http.StatusBadRequest)
+			return
+		}
+		selfieData, err = decodeImage(thisRequest.SelfieImage)
+		if err != nil {
+			respondWithError(w, "Invalid Base64 string for selfie_image", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if len(documentData) == 0 || len(selfieData) == 0 {
+		respondWithError(w, "All fields are required", http.StatusBadRequest)
+		return
+	}
+
+	if http.DetectContentType(documentData) != "image/jpeg" || http.DetectContentType(selfieData) != "image/jpeg" {
+		respondWithCode(w, CodeUnsupportedFormat, "Unsupported image format", http.StatusBadRequest, nil)
+		return
+	}
+
+	documentImage, _, err := image.Decode(bytes.NewReader(documentData))
+	if err != nil {
+		respondWithCode(w, CodeInvalidImage, "Failed to decode document_image", http.StatusBadRequest, nil)
+		return
+	}
+	selfieImage, _, err := image.Decode(bytes.NewReader(selfieData))
+	if err != nil {
+		respondWithCode(w, CodeInvalidImage, "Failed to decode selfie_image", http.StatusBadRequest, nil)
+		return
+	}
+
+	// Documents often carry a second, smaller ghost portrait, so the largest
+	// face is the holder's photo regardless of the configured policy.
+	documentFaces, err := core.DetectFaces(documentData)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to detect faces on document", "error", err)
+		respondWithCode(w, CodeInvalidImage, "Failed to process document_image", http.StatusUnprocessableEntity, nil)
+		return
+	}
+	if len(documentFaces) == 0 {
+		respondWithCode(w, CodeNoFace, "Failed to find a face on document_image", http.StatusUnprocessableEntity, map[string]interface{}{"image": "document_image", "faces_detected": 0})
+		return
+	}
+	documentFace := core.LargestFace(documentFaces)
+
+	selfieFace, err := core.CheckFaceData(selfieData)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to find a face on selfie", "error", err)
+		if !respondWithCoreError(w, err, map[string]interface{}{"image": "selfie_image"}) {
+			respondWithCode(w, CodeInvalidImage, "Failed to process selfie_image", http.StatusUnprocessableEntity, nil)
+		}
+		return
+	}
+
+	threshold := tenantOf(r).Policy().MatchThreshold
+	comparison, err := core.CompareFaces(documentFace, selfieFace, threshold)
+	if err != nil && err != core.ErrNoMatch {
+		respondWithError(w, "Failed to compare faces", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.CompareResponse{
+		Distance:  comparison.Distance,
+		Threshold: threshold,
+		Match:     comparison.Match,
+		Document:  describeFace(documentImage, documentFace),
+		Selfie:    describeFace(selfieImage, selfieFace),
+	})
+}
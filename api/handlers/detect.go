@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"bytes"
+	"image"
+	"log/slog"
+	"net/http"
+
+	"github.com/Adedunmol/face-widget/api/models"
+	"github.com/Adedunmol/face-widget/core"
+	"github.com/Kagami/go-face"
+)
+
+func DetectFaces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var thisRequest models.DetectPayload
+	if err := readJSON(r, &thisRequest); err != nil {
+		respondWithPayloadError(w, err)
+		return
+	}
+
+	if thisRequest.EncodedImage == "" {
+		respondWithError(w, "All fields are required", http.StatusBadRequest)
+		return
+	}
+
+	decodedData, err := decodeImage(thisRequest.EncodedImage)
+	if err != nil {
+		respondWithError(w, "Invalid Base64 string", http.StatusBadRequest)
+		return
+	}
+
+	fileType := http.DetectContentType(decodedData)
+	if fileType != "image/jpeg" {
+		respondWithCode(w, CodeUnsupportedFormat, "Unsupported image format", http.StatusBadRequest, nil)
+		return
+	}
+
+	faces, err := core.DetectFaces(decodedData)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to detect faces", "error", err)
+		respondWithCode(w, CodeInvalidImage, "Failed to process image", http.StatusUnprocessableEntity, nil)
+		return
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(decodedData))
+	if err != nil {
+		respondWithCode(w, CodeInvalidImage, "Failed to decode image", http.StatusBadRequest, nil)
+		return
+	}
+
+	// Nothing is written to disk or the database: the image only lives for
+	// the duration of the request.
+	response := models.DetectResponse{
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+		Count:  len(faces),
+		Faces:  []models.DetectedFace{},
+	}
+	for i := range faces {
+		response.Faces = append(response.Faces, describeFace(img, &faces[i]))
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func describeFace(img image.Image, f *face.Face) models.DetectedFace {
+	landmarks := make([]models.Point, 0, len(f.Shapes))
+	for _, p := range f.Shapes {
+		landmarks = append(landmarks, models.Point{X: p.X, Y: p.Y})
+	}
+
+	quality := core.AssessQuality(img, f.Rectangle)
+
+	return models.DetectedFace{
+		Box:       toBox(f.Rectangle),
+		Landmarks: landmarks,
+		Size:      quality.FaceSize,
+		Pose:      poseOf(f),
+		Quality:   quality,
+	}
+}
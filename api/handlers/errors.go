@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Adedunmol/face-widget/api/middleware"
+	"github.com/Adedunmol/face-widget/api/models"
+	"github.com/Adedunmol/face-widget/core"
+)
+
+// Stable error codes returned in the error envelope. Clients should match on
+// these rather than on the message. CodeNoMatch and CodeUserNotFound on
+// verification are only recorded as reasons; the caller gets
+// CodeInvalidCredentials.
+const (
+	CodeInvalidRequest     = "invalid_request"
+	CodeInvalidImage       = "invalid_image"
+	CodeUnsupportedFormat  = "unsupported_image_format"
+	CodeImageTooLarge      = "image_too_large"
+	CodeBodyTooLarge       = "body_too_large"
+	CodeNoFace             = "no_face"
+	CodeMultipleFaces      = "multiple_faces"
+	CodePoseNotMatched     = "pose_not_matched"
+	CodeIdentityMismatch   = "identity_mismatch"
+	CodeLivenessFailed     = "liveness_failed"
+	CodeNoMatch            = "no_match"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeInsufficientScope  = "insufficient_scope"
+	CodeOriginNotAllowed   = "origin_not_allowed"
+	CodeUserNotFound       = "user_not_found"
+	CodeEmailExists        = "email_exists"
+	CodeAPIKeyNotFound     = "api_key_not_found"
+	CodeWebhookNotFound    = "webhook_not_found"
+	CodeDeliveryNotFound   = "delivery_not_found"
+	CodeDeliveryPending    = "delivery_pending"
+	CodeRateLimited        = "rate_limited"
+	CodeAccountLocked      = "account_locked"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeStorageError       = "storage_error"
+	CodeInternal           = "internal_error"
+)
+
+// defaultCodes gives errors reported only with a status their code. Only the
+// statuses with a single meaning have one; any other error names its code
+// with respondWithCode.
+var defaultCodes = map[int]string{
+	http.StatusBadRequest:          CodeInvalidRequest,
+	http.StatusMethodNotAllowed:    CodeMethodNotAllowed,
+	http.StatusInternalServerError: CodeInternal,
+}
+
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+	Details map[string]interface{}
+}
+
+// writeError renders err in the /v1 envelope, or in the legacy
+// {"error": "..."} shape for the deprecated unversioned routes. The legacy
+// shape gains the code and details as extra top-level fields.
+func writeError(w http.ResponseWriter, err apiError) {
+	if err.Code == "" {
+		err.Code = defaultCodes[err.Status]
+		if err.Code == "" {
+			err.Code = CodeInternal
+		}
+	}
+	if recorder, ok := w.(*auditWriter); ok {
+		recorder.code = err.Code
+	}
+
+	if middleware.IsDeprecated(w) {
+		payload := map[string]interface{}{}
+		for key, value := range err.Details {
+			payload[key] = value
+		}
+		payload["error"] = err.Message
+		payload["code"] = err.Code
+		respondWithJSON(w, err.Status, payload)
+		return
+	}
+
+	respondWithJSON(w, err.Status, models.ErrorResponse{
+		Error: models.ErrorBody{
+			Code:      err.Code,
+			Message:   err.Message,
+			RequestID: middleware.ResponseRequestID(w),
+			Details:   err.Details,
+		},
+	})
+}
+
+func respondWithError(w http.ResponseWriter, message string, status int) {
+	writeError(w, apiError{Status: status, Message: message})
+}
+
+func respondWithCode(w http.ResponseWriter, code, message string, status int, details map[string]interface{}) {
+	writeError(w, apiError{Status: status, Code: code, Message: message, Details: details})
+}
+
+// coreErrors maps the recognition errors of package core to API errors.
+var coreErrors = []struct {
+	err error
+	apiError
+}{
+	{core.ErrNoFaceFound, apiError{Status: http.StatusUnprocessableEntity, Code: CodeNoFace, Message: "Failed to find a face"}},
+	{core.ErrMultipleFaces, apiError{Status: http.StatusUnprocessableEntity, Code: CodeMultipleFaces, Message: "Multiple faces found"}},
+	{core.ErrInvalidFormat, apiError{Status: http.StatusBadRequest, Code: CodeUnsupportedFormat, Message: "Unsupported image format"}},
+	{core.ErrDecodingImage, apiError{Status: http.StatusBadRequest, Code: CodeInvalidImage, Message: "Could not decode image"}},
+	{core.ErrPoseNotMatched, apiError{Status: http.StatusUnprocessableEntity, Code: CodePoseNotMatched, Message: "Face is not in the requested pose"}},
+	{core.ErrNotSamePerson, apiError{Status: http.StatusUnprocessableEntity, Code: CodeIdentityMismatch, Message: "Frames do not show the same person"}},
+	{core.ErrNotLive, apiError{Status: http.StatusUnprocessableEntity, Code: CodeLivenessFailed, Message: "Liveness check failed"}},
+	{core.ErrNoMatch, apiError{Status: http.StatusUnauthorized, Code: CodeInvalidCredentials, Message: "Invalid credentials"}},
+}
+
+// respondWithCoreError answers with the API error matching a core error,
+// adding details such as the number of faces detected. It reports whether
+// err was a known core error.
+func respondWithCoreError(w http.ResponseWriter, err error, details map[string]interface{}) bool {
+	for _, known := range coreErrors {
+		if !errors.Is(err, known.err) {
+			continue
+		}
+
+		apiErr := known.apiError
+		apiErr.Details = details
+		var countErr *core.FaceCountError
+		if errors.As(err, &countErr) {
+			apiErr.Details = withDetail(apiErr.Details, "faces_detected", countErr.Count)
+		}
+		writeError(w, apiErr)
+		return true
+	}
+	return false
+}
+
+// respondVerificationFailed answers every failed verification alike, for an
+// unknown email, a wrong face or failed liveness, so that callers learn
+// neither whether an email is enrolled nor which check stopped them. The
+// reason is kept in the audit log and the webhook events.
+func respondVerificationFailed(w http.ResponseWriter) {
+	respondWithCode(w, CodeInvalidCredentials, "Invalid credentials", http.StatusUnauthorized, nil)
+}
+
+// respondWithPayloadError answers a request whose body could not be read.
+func respondWithPayloadError(w http.ResponseWriter, err error) {
+	var payloadErr *payloadError
+	if errors.As(err, &payloadErr) {
+		status := payloadErr.status
+		if status == 0 {
+			status = http.StatusBadRequest
+		}
+		respondWithCode(w, payloadErr.code, payloadErr.message, status, payloadErr.details)
+		return
+	}
+	respondWithError(w, "Invalid request payload", http.StatusBadRequest)
+}
+
+func withDetail(details map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	details[key] = value
+	return details
+}
@@ -2,7 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"image"
 	"net/http"
+
+	"github.com/Adedunmol/face-widget/api/models"
+	"github.com/Adedunmol/face-widget/core"
+	"github.com/Adedunmol/face-widget/metrics"
+	"github.com/Kagami/go-face"
 )
 
 func respondWithJSON(w http.ResponseWriter, status int, payload interface{}) {
@@ -17,6 +23,37 @@ func respondWithJSON(w http.ResponseWriter, status int, payload interface{}) {
 	w.Write(response)
 }
 
-func respondWithError(w http.ResponseWriter, message string, status int) {
-	respondWithJSON(w, status, map[string]string{"error": message})
+func toBox(rect image.Rectangle) models.Box {
+	return models.Box{
+		X:      rect.Min.X,
+		Y:      rect.Min.Y,
+		Width:  rect.Dx(),
+		Height: rect.Dy(),
+	}
+}
+
+// poseOf returns the estimated head pose, or nil when the face carries no
+// usable landmarks.
+func poseOf(f *face.Face) *core.Pose {
+	if f == nil {
+		return nil
+	}
+	pose, err := core.EstimatePose(*f)
+	if err != nil {
+		return nil
+	}
+	return &pose
+}
+
+// observeVerification records the distance of a verification that got as far
+// as comparing faces.
+func observeVerification(method string, comparison *core.Comparison, err error) {
+	if comparison == nil {
+		return
+	}
+	outcome := "accepted"
+	if err != nil {
+		outcome = "rejected"
+	}
+	metrics.VerificationDistance.Observe(comparison.Distance, method, outcome)
 }
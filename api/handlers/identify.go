@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"log/slog"
+	"math"
+	"net/http"
+
+	"github.com/Adedunmol/face-widget/api/models"
+	"github.com/Adedunmol/face-widget/core"
+)
+
+// IdentifyFaces matches every face on a group photo against the enrolled
+// users.
+func IdentifyFaces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var thisRequest models.IdentifyPayload
+	if err := readJSON(r, &thisRequest); err != nil {
+		respondWithPayloadError(w, err)
+		return
+	}
+
+	if thisRequest.EncodedImage == "" {
+		respondWithError(w, "All fields are required", http.StatusBadRequest)
+		return
+	}
+
+	decodedData, err := decodeImage(thisRequest.EncodedImage)
+	if err != nil {
+		respondWithError(w, "Invalid Base64 string", http.StatusBadRequest)
+		return
+	}
+
+	fileType := http.DetectContentType(decodedData)
+	if fileType != "image/jpeg" {
+		respondWithCode(w, CodeUnsupportedFormat, "Unsupported image format", http.StatusBadRequest, nil)
+		return
+	}
+
+	faces, err := core.DetectFaces(decodedData)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to detect faces", "error", err)
+		respondWithCode(w, CodeInvalidImage, "Failed to process image", http.StatusUnprocessableEntity, nil)
+		return
+	}
+
+	tenant := tenantOf(r)
+	gallery, users, err := loadGallery(context.WithoutCancel(r.Context()), tenant.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to load enrolled faces", "error", err)
+		respondWithError(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.IdentifyResponse{Faces: []models.IdentifiedFace{}}
+	var labels []core.Label
+	threshold := tenant.Policy().MatchThreshold
+	for _, f := range faces {
+		identification := core.IdentifyFace(f.Descriptor, gallery, threshold)
+
+		identified := models.IdentifiedFace{
+			Box:     toBox(f.Rectangle),
+			Matched: identification.Matched,
+		}
+		if !math.IsInf(identification.Distance, 1) {
+			identified.Distance = &identification.Distance
+		}
+		label := core.Label{Rect: f.Rectangle, Text: "unknown"}
+		if identification.Matched {
+			user := users[identification.UserID]
+			identified.User = &user
+			label.Text = user.FirstName + " " + user.LastName
+			label.Known = true
+		}
+
+		response.Faces = append(response.Faces, identified)
+		labels = append(labels, label)
+	}
+
+	if thisRequest.Annotate {
+		img, _, err := image.Decode(bytes.NewReader(decodedData))
+		if err != nil {
+			respondWithCode(w, CodeInvalidImage, "Failed to decode image", http.StatusBadRequest, nil)
+			return
+		}
+
+		annotated, err := core.Annotate(img, labels)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to annotate image", "error", err)
+			respondWithError(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+		response.AnnotatedImage = base64.StdEncoding.EncodeToString(annotated)
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Adedunmol/face-widget/api/tenants"
+	"github.com/Adedunmol/face-widget/core"
+	"github.com/Adedunmol/face-widget/metrics"
+	"github.com/Adedunmol/face-widget/tracing"
+	"github.com/Kagami/go-face"
+)
+
+type livenessFrame struct {
+	Image []byte
+	Face  *face.Face
+}
+
+// decodeLivenessFrames checks a /verify_user style frame sequence and
+// detects the face on every frame. It writes the error response itself and
+// returns a non-nil error when the request must stop.
+func decodeLivenessFrames(ctx context.Context, w http.ResponseWriter, frameImages [][]byte) ([]livenessFrame, []core.FrameData, error) {
+	ctx, span := tracing.Start(ctx, "decode_frames", tracing.Int("frames", len(frameImages)))
+	defer span.End()
+
+	var decoded []livenessFrame
+	var frames []core.FrameData
+	for i, decodedData := range frameImages {
+		fileType := http.DetectContentType(decodedData)
+		if fileType != "image/jpeg" {
+			respondWithCode(w, CodeUnsupportedFormat, "Unsupported image format for frame "+strconv.Itoa(i+1), http.StatusBadRequest, map[string]interface{}{"frame": i + 1})
+			return nil, nil, core.ErrInvalidFormat
+		}
+
+		_, frameSpan := tracing.Start(ctx, "detect_face", tracing.Int("frame", i+1))
+		detected, err := core.CheckFaceData(decodedData)
+		frameSpan.RecordError(err)
+		frameSpan.End()
+		if err != nil {
+			span.RecordError(err)
+			slog.InfoContext(ctx, "No usable face found on frame", "frame", i+1, "error", err)
+			details := map[string]interface{}{"frame": i + 1}
+			if !respondWithCoreError(w, err, details) {
+				respondWithCode(w, CodeNoFace, "Failed to find a face", http.StatusUnprocessableEntity, details)
+			}
+			return nil, nil, err
+		}
+
+		decoded = append(decoded, livenessFrame{Image: decodedData, Face: detected})
+		frames = append(frames, core.FrameData{
+			Descriptor: detected.Descriptor,
+			Rect:       detected.Rectangle,
+		})
+	}
+
+	return decoded, frames, nil
+}
+
+// checkLiveness runs the same-identity check followed by the motion checks
+// of the tenant's policy over a frame sequence.
+func checkLiveness(ctx context.Context, frames []core.FrameData, policy tenants.Policy) (_ core.LivenessResult, err error) {
+	ctx, span := tracing.Start(ctx, "liveness")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// 1. Check for same identity
+	samePerson := core.IsSamePerson(frames, policy.MatchThreshold)
+	slog.DebugContext(ctx, "Checked frame identity", "same_person", samePerson)
+	if !samePerson {
+		return core.LivenessResult{}, core.ErrNotSamePerson
+	}
+
+	// 2. Check for movement
+	liveness := core.CheckLiveness(frames, livenessThresholds(policy))
+	slog.DebugContext(ctx, "Checked liveness", "rect_motion", liveness.RectMotion, "descriptor_shift", liveness.DescriptorShift)
+	span.SetAttributes(
+		tracing.Float64("liveness.rect_motion", liveness.RectMotion),
+		tracing.Float64("liveness.descriptor_shift", liveness.DescriptorShift),
+	)
+	metrics.LivenessRectMotion.Observe(liveness.RectMotion)
+	metrics.LivenessDescriptorShift.Observe(liveness.DescriptorShift)
+	if !liveness.Live {
+		return liveness, core.ErrNotLive
+	}
+
+	return liveness, nil
+}
+
+func livenessThresholds(policy tenants.Policy) core.LivenessThresholds {
+	return core.LivenessThresholds{
+		MaxRectMotion:      policy.LivenessMaxRectMotion,
+		MinDescriptorShift: policy.LivenessMinDescriptorShift,
+	}
+}
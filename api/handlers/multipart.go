@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxImageBytes bounds a single uploaded image part.
+const maxImageBytes = 10 << 20
+
+var errImageTooLarge = errors.New("image part too large")
+
+// multipartForm holds the text fields and file parts of a multipart body.
+// File parts are read straight from the request stream into memory and are
+// never written to disk.
+type multipartForm struct {
+	Values map[string][]string
+	Files  map[string][][]byte
+}
+
+func (f *multipartForm) Value(name string) string {
+	if values := f.Values[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (f *multipartForm) File(name string) []byte {
+	if files := f.Files[name]; len(files) > 0 {
+		return files[0]
+	}
+	return nil
+}
+
+func isMultipart(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && strings.HasPrefix(mediaType, "multipart/form-data")
+}
+
+func readMultipart(r *http.Request) (*multipartForm, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	form := &multipartForm{
+		Values: map[string][]string{},
+		Files:  map[string][][]byte{},
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, maxImageBytes+1))
+		part.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxImageBytes {
+			return nil, errImageTooLarge
+		}
+
+		name := part.FormName()
+		if part.FileName() != "" {
+			form.Files[name] = append(form.Files[name], data)
+		} else {
+			form.Values[name] = append(form.Values[name], string(data))
+		}
+	}
+}
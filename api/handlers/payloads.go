@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Adedunmol/face-widget/api/models"
+	"github.com/Adedunmol/face-widget/core"
+	"github.com/gorilla/schema"
+)
+
+// The payload readers accept both JSON bodies with Base64 images and
+// multipart/form-data bodies with file parts. Their errors are meant for the
+// client and map to 400 Bad Request unless they carry another status.
+
+type payloadError struct {
+	status  int
+	code    string
+	message string
+	details map[string]interface{}
+}
+
+func (e *payloadError) Error() string {
+	return e.message
+}
+
+var (
+	errReadingBody    = &payloadError{code: CodeInvalidRequest, message: "Error reading request body"}
+	errInvalidPayload = &payloadError{code: CodeInvalidRequest, message: "Invalid request payload"}
+	errInvalidBase64  = &payloadError{code: CodeInvalidImage, message: "Invalid Base64 string"}
+	errBodyTooLarge   = &payloadError{status: http.StatusRequestEntityTooLarge, code: CodeBodyTooLarge, message: "Request body too large"}
+)
+
+// readError tells a body cut off by the route's size limit from other read
+// errors.
+func readError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return errBodyTooLarge
+	}
+	return errReadingBody
+}
+
+// multipartError maps the errors of readMultipart to payload errors.
+func multipartError(err error) error {
+	if err == errImageTooLarge {
+		return &payloadError{code: CodeImageTooLarge, message: "Image too large"}
+	}
+	return readError(err)
+}
+
+var formDecoder = newFormDecoder()
+
+func newFormDecoder() *schema.Decoder {
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+	return decoder
+}
+
+// decodeImage decodes a Base64 image, with or without a data URI prefix such
+// as "data:image/jpeg;base64,".
+func decodeImage(encoded string) ([]byte, error) {
+	if strings.HasPrefix(encoded, "data:") {
+		comma := strings.IndexByte(encoded, ',')
+		if comma < 0 || !strings.HasSuffix(encoded[:comma], ";base64") {
+			return nil, errors.New("invalid data URI")
+		}
+		encoded = encoded[comma+1:]
+	}
+
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+}
+
+// decodeFrames decodes a list of Base64 frames. pose names the enrollment
+// pose the frames belong to, if any, for the error message.
+func decodeFrames(encodedFrames []string, pose string) ([][]byte, error) {
+	var frames [][]byte
+	for i, frame := range encodedFrames {
+		decodedData, err := decodeImage(frame)
+		if err != nil {
+			details := map[string]interface{}{"frame": i + 1}
+			label := "frame"
+			if pose != "" {
+				details["pose"] = pose
+				label = pose + " frame"
+			}
+			return nil, &payloadError{
+				code:    CodeInvalidImage,
+				message: fmt.Sprintf("Invalid base64 string for %s %d", label, i+1),
+				details: details,
+			}
+		}
+		frames = append(frames, decodedData)
+	}
+	return frames, nil
+}
+
+func readJSON(r *http.Request, payload interface{}) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return readError(err)
+	}
+
+	if err := json.Unmarshal(body, payload); err != nil {
+		return errInvalidPayload
+	}
+	return nil
+}
+
+func readForm(r *http.Request, payload interface{}) (*multipartForm, error) {
+	form, err := readMultipart(r)
+	if err != nil {
+		return nil, multipartError(err)
+	}
+
+	if err := formDecoder.Decode(payload, form.Values); err != nil {
+		return nil, errInvalidPayload
+	}
+	return form, nil
+}
+
+func readRegisterPayload(r *http.Request) (models.RegisterPayload, error) {
+	var payload models.RegisterPayload
+
+	if isMultipart(r) {
+		form, err := readForm(r, &payload)
+		if err != nil {
+			return payload, err
+		}
+		payload.FacePayload = facePayloadFromForm(form)
+		return payload, nil
+	}
+
+	if err := readJSON(r, &payload); err != nil {
+		return payload, err
+	}
+
+	return payload, decodeFacePayload(&payload.FacePayload)
+}
+
+func readReplaceFacePayload(r *http.Request) (models.ReplaceFacePayload, error) {
+	var payload models.ReplaceFacePayload
+
+	if isMultipart(r) {
+		form, err := readForm(r, &payload)
+		if err != nil {
+			return payload, err
+		}
+		payload.FacePayload = facePayloadFromForm(form)
+		return payload, nil
+	}
+
+	if err := readJSON(r, &payload); err != nil {
+		return payload, err
+	}
+	return payload, decodeFacePayload(&payload.FacePayload)
+}
+
+func facePayloadFromForm(form *multipartForm) models.FacePayload {
+	payload := models.FacePayload{
+		Image:       form.File("facial_image"),
+		FrameImages: form.Files["frames"],
+	}
+	if len(form.Files["front"])+len(form.Files["slight_left"])+len(form.Files["slight_right"]) > 0 {
+		payload.Enrollment = &models.EnrollmentFrames{
+			FrontImages:       form.Files["front"],
+			SlightLeftImages:  form.Files["slight_left"],
+			SlightRightImages: form.Files["slight_right"],
+		}
+	}
+	return payload
+}
+
+func decodeFacePayload(payload *models.FacePayload) error {
+	var err error
+	if payload.EncodedImage != "" {
+		if payload.Image, err = decodeImage(payload.EncodedImage); err != nil {
+			return errInvalidBase64
+		}
+	}
+	if payload.FrameImages, err = decodeFrames(payload.Frames, ""); err != nil {
+		return err
+	}
+	if enrollment := payload.Enrollment; enrollment != nil {
+		if enrollment.FrontImages, err = decodeFrames(enrollment.Front, string(core.PoseFront)); err != nil {
+			return err
+		}
+		if enrollment.SlightLeftImages, err = decodeFrames(enrollment.SlightLeft, string(core.PoseSlightLeft)); err != nil {
+			return err
+		}
+		if enrollment.SlightRightImages, err = decodeFrames(enrollment.SlightRight, string(core.PoseSlightRight)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readVerifyUserPayload(r *http.Request) (models.VerifyUserPayload, error) {
+	var payload models.VerifyUserPayload
+
+	if isMultipart(r) {
+		form, err := readForm(r, &payload)
+		if err != nil {
+			return payload, err
+		}
+		payload.Image = form.File("facial_image")
+		return payload, nil
+	}
+
+	if err := readJSON(r, &payload); err != nil {
+		return payload, err
+	}
+
+	if payload.EncodedImage != "" {
+		var err error
+		if payload.Image, err = decodeImage(payload.EncodedImage); err != nil {
+			return payload, errInvalidBase64
+		}
+	}
+	return payload, nil
+}
+
+func readNewVerifyUserPayload(r *http.Request) (models.NewVerifyUserPayload, error) {
+	var payload models.NewVerifyUserPayload
+
+	if isMultipart(r) {
+		form, err := readForm(r, &payload)
+		if err != nil {
+			return payload, err
+		}
+		payload.FrameImages = form.Files["frames"]
+		return payload, nil
+	}
+
+	if err := readJSON(r, &payload); err != nil {
+		return payload, err
+	}
+
+	var err error
+	payload.FrameImages, err = decodeFrames(payload.Frames, "")
+	return payload, err
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Adedunmol/face-widget/api/apikeys"
+	"github.com/Adedunmol/face-widget/api/config"
+	"github.com/Adedunmol/face-widget/api/ratelimit"
+)
+
+// clientIP returns the address of the client, as seen by the proxy in front
+// of the server when it is trusted.
+func clientIP(r *http.Request) string {
+	if config.Cfg.RateLimit.TrustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			hops := strings.Split(forwarded, ",")
+			return strings.TrimSpace(hops[len(hops)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// verificationAttempt describes a verification of userID, which is 0 when
+// the user is unknown.
+func verificationAttempt(r *http.Request, userID int) ratelimit.Attempt {
+	attempt := ratelimit.Attempt{UserID: userID, IP: clientIP(r)}
+	if key := apikeys.FromContext(r.Context()); key != nil {
+		attempt.APIKey = key.ID
+	}
+	return attempt
+}
+
+// reserveAttempt answers 429 with Retry-After and returns nil when the
+// attempt is over a limit or the account is locked. The attempt it lets
+// through counts as failed unless attemptSucceeded releases it, whatever
+// stops the verification.
+func reserveAttempt(w http.ResponseWriter, r *http.Request, attempt ratelimit.Attempt) *ratelimit.Reservation {
+	reservation, err := ratelimit.Verifications.Reserve(r.Context(), attempt)
+	if err == nil {
+		return reservation
+	}
+
+	var limitErr *ratelimit.LimitError
+	if !errors.As(err, &limitErr) {
+		slog.ErrorContext(r.Context(), "Failed to check rate limits", "error", err)
+		respondWithError(w, "Server Error", http.StatusInternalServerError)
+		return nil
+	}
+
+	retryAfter := int(math.Ceil(limitErr.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	details := map[string]interface{}{"limit": limitErr.Reason, "retry_after": retryAfter}
+	if limitErr.Reason == ratelimit.ReasonLocked {
+		respondWithCode(w, CodeAccountLocked, "Account locked after too many failed verifications", http.StatusTooManyRequests, details)
+		return nil
+	}
+	respondWithCode(w, CodeRateLimited, "Too many failed verifications, try again later", http.StatusTooManyRequests, details)
+	return nil
+}
+
+func attemptSucceeded(r *http.Request, reservation *ratelimit.Reservation) {
+	if err := ratelimit.Verifications.Succeeded(r.Context(), reservation); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to reset failed verifications", "error", err)
+	}
+}
+
+// UnlockUser lifts the lockout of a user and forgets its failed
+// verifications.
+func UnlockUser(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+
+	_, _, err := loadUser(r.Context(), tenantOf(r).ID, id)
+	if err == sql.ErrNoRows {
+		respondWithCode(w, CodeUserNotFound, "User not found", http.StatusNotFound, nil)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to look up user", "error", err)
+		respondWithError(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := ratelimit.Verifications.Unlock(r.Context(), id); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to unlock user", "error", err)
+		respondWithError(w, "Failed to unlock user", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,21 +2,18 @@ package handlers
 
 import (
 	"context"
-	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
-	"time"
 
-	"github.com/Adedunmol/face-widget/api/db"
+	"github.com/Adedunmol/face-widget/api/audit"
+	"github.com/Adedunmol/face-widget/api/config"
 	"github.com/Adedunmol/face-widget/api/models"
+	"github.com/Adedunmol/face-widget/api/tenants"
+	"github.com/Adedunmol/face-widget/api/webhooks"
 	"github.com/Adedunmol/face-widget/core"
 
-	"github.com/cloudinary/cloudinary-go/v2"
-	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
 	"github.com/lib/pq"
 )
 
@@ -26,105 +23,241 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		respondWithError(w, "Error reading request body", http.StatusBadRequest)
-		return
-	}
+	w, trail := startAudit(w, r, audit.ActionRegister, "")
+	defer trail.record(r)
 
-	var thisRequest models.RegisterPayload
-	err = json.Unmarshal(body, &thisRequest)
+	thisRequest, err := readRegisterPayload(r)
 	if err != nil {
-		respondWithError(w, "Invalid request payload", http.StatusBadRequest)
+		respondWithPayloadError(w, err)
 		return
 	}
 
 	if thisRequest.Email == "" ||
 		thisRequest.FirstName == "" ||
-		thisRequest.LastName == "" ||
-		thisRequest.EncodedImage == "" {
+		thisRequest.LastName == "" {
 		respondWithError(w, "All fields are required", http.StatusBadRequest)
 		return
 	}
 
-	// 1. Decode the Base64 string into bytes.
-	decodedData, err := base64.StdEncoding.DecodeString(thisRequest.EncodedImage)
-	if err != nil {
-		respondWithError(w, "Invalid Base64 string: "+err.Error(), http.StatusBadRequest)
-		return
-	}
+	tenant := tenantOf(r)
 
-	// 2. Detect the content type (image format) from the decoded bytes.
-	fileType := http.DetectContentType(decodedData)
-	if fileType != "image/jpeg" {
-		respondWithError(w, "Unsupported image format", http.StatusBadRequest)
+	samples, err := enrollmentSamples(r.Context(), w, tenant.Policy(), thisRequest.FacePayload)
+	if err != nil {
 		return
 	}
 
-	baseImageFilename := fmt.Sprintf(
-		"%d_%s%s%s%s",
-		time.Now().Unix(),
-		thisRequest.FirstName,
-		thisRequest.LastName,
-		"BaseImage",
-		".jpg",
-	)
-	baseFilepath := fmt.Sprintf("./images/%s", baseImageFilename)
+	ctx := context.WithoutCancel(r.Context())
 
-	if err := os.WriteFile(baseFilepath, decodedData, 0644); err != nil {
-		log.Printf("Failed to save verificationImage file: %v", err)
-		respondWithError(w, "Server Error", http.StatusInternalServerError)
+	imageRefs, err := uploadSamples(ctx, tenant, samples)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to upload file", "error", err)
+		respondWithCode(w, CodeStorageError, "Error uploading image", http.StatusInternalServerError, nil)
 		return
 	}
-	defer os.Remove(baseFilepath)
 
-	_, err = core.CheckFace(baseFilepath)
+	userID, err := insertUserWithSamples(ctx, tenant.ID, thisRequest, samples, imageRefs)
 	if err != nil {
-		log.Printf("Failed to recognize file: %v", err)
-		respondWithError(w, "Failed to find a face", http.StatusUnprocessableEntity)
+		deleteImages(ctx, imageRefs)
+		if dbError, ok := err.(*pq.Error); ok && dbError.Code.Name() == "unique_violation" {
+			respondWithCode(w, CodeEmailExists, "Email already exists", http.StatusConflict, nil)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to register user", "error", err)
+		respondWithError(w, "Failed to register user", http.StatusInternalServerError)
 		return
 	}
 
-	ctx := context.Background()
+	trail.setUser(userID)
+	publishEvent(r, webhooks.EventUserRegistered, webhooks.UserData{UserID: userID})
 
-	cld, err := cloudinary.New()
+	respondWithJSON(w, http.StatusCreated, map[string]string{"message": "Registration successful!"})
+}
+
+// enrollmentSamples turns the face of a registration or face replacement
+// into enrollment samples. It writes the error response itself and returns a
+// non-nil error when the request must stop.
+func enrollmentSamples(ctx context.Context, w http.ResponseWriter, policy tenants.Policy, facePayload models.FacePayload) ([]core.EnrollmentSample, error) {
+	if len(facePayload.Image) == 0 && facePayload.Enrollment == nil && len(facePayload.FrameImages) == 0 {
+		respondWithError(w, "All fields are required", http.StatusBadRequest)
+		return nil, core.ErrNoFaceFound
+	}
+
+	if policy.RequireLivenessAtRegistration && len(facePayload.FrameImages) == 0 {
+		respondWithError(w, "A liveness frame sequence is required", http.StatusBadRequest)
+		return nil, core.ErrNotLive
+	}
+
+	switch {
+	case len(facePayload.FrameImages) > 0:
+		return livenessEnrollmentSample(ctx, w, policy, facePayload.FrameImages)
+	case facePayload.Enrollment != nil:
+		return guidedEnrollmentSamples(ctx, w, policy, facePayload.Enrollment)
+	default:
+		return singleImageSample(ctx, w, facePayload.Image)
+	}
+}
+
+// singleImageSample validates a single facial_image and turns it into a
+// frontal enrollment sample. Like enrollmentSamples it writes the error
+// response itself.
+func singleImageSample(ctx context.Context, w http.ResponseWriter, decodedData []byte) ([]core.EnrollmentSample, error) {
+	// Detect the content type (image format) from the decoded bytes.
+	fileType := http.DetectContentType(decodedData)
+	if fileType != "image/jpeg" {
+		respondWithCode(w, CodeUnsupportedFormat, "Unsupported image format", http.StatusBadRequest, nil)
+		return nil, core.ErrInvalidFormat
+	}
+
+	detected, err := core.CheckFaceData(decodedData)
 	if err != nil {
-		log.Printf("Failed to create Cloudinary instance: %v", err)
-		respondWithError(w, "Error creating Cloudinary instance", http.StatusInternalServerError)
-		return
+		slog.ErrorContext(ctx, "Failed to recognize file", "error", err)
+		if !respondWithCoreError(w, err, nil) {
+			respondWithCode(w, CodeNoFace, "Failed to find a face", http.StatusUnprocessableEntity, nil)
+		}
+		return nil, err
+	}
+
+	pose := poseOf(detected)
+	if !frontalEnough(pose) {
+		respondWithCode(w, CodePoseNotMatched, "Face must be looking straight at the camera", http.StatusUnprocessableEntity, nil)
+		return nil, core.ErrPoseNotMatched
+	}
+
+	sample := core.EnrollmentSample{
+		Pose:  core.PoseFront,
+		Image: decodedData,
+		Face:  *detected,
+	}
+	if pose != nil {
+		sample.HeadPose = *pose
 	}
+	sample.Quality, _ = core.AssessQualityJPEG(decodedData, detected.Rectangle)
 
-	uploadResult, err := cld.Upload.Upload(ctx, baseFilepath, uploader.UploadParams{})
+	return []core.EnrollmentSample{sample}, nil
+}
+
+// guidedEnrollmentSamples checks the frames of a guided enrollment and keeps
+// the best frame for each pose. Like enrollmentSamples it writes the error
+// response itself.
+func guidedEnrollmentSamples(ctx context.Context, w http.ResponseWriter, policy tenants.Policy, enrollment *models.EnrollmentFrames) ([]core.EnrollmentSample, error) {
+	submitted := map[core.EnrollmentPose][][]byte{
+		core.PoseFront:       enrollment.FrontImages,
+		core.PoseSlightLeft:  enrollment.SlightLeftImages,
+		core.PoseSlightRight: enrollment.SlightRightImages,
+	}
+
+	for _, pose := range core.EnrollmentPoses {
+		if len(submitted[pose]) == 0 {
+			respondWithCode(w, CodeInvalidRequest, fmt.Sprintf("At least one %s frame is required", pose), http.StatusBadRequest, map[string]interface{}{"pose": pose})
+			return nil, core.ErrPoseNotMatched
+		}
+
+		for i, decodedData := range submitted[pose] {
+			if http.DetectContentType(decodedData) != "image/jpeg" {
+				respondWithCode(w, CodeUnsupportedFormat, fmt.Sprintf("Unsupported image format for %s frame %d", pose, i+1), http.StatusBadRequest, map[string]interface{}{"pose": pose, "frame": i + 1})
+				return nil, core.ErrInvalidFormat
+			}
+		}
+	}
+
+	samples, err := core.SelectEnrollmentSamples(submitted, enrollmentLimits(), policy.MatchThreshold)
 	if err != nil {
-		log.Printf("Failed to upload file: %v", err)
-		respondWithError(w, "Error uploading image to Cloudinary", http.StatusInternalServerError)
-		return
+		slog.ErrorContext(ctx, "Guided enrollment failed", "error", err)
+
+		var frameErr *core.FrameError
+		switch {
+		case errors.Is(err, core.ErrNotSamePerson):
+			respondWithCoreError(w, err, nil)
+		case errors.Is(err, core.ErrPoseNotMatched) && errors.As(err, &frameErr):
+			respondWithCode(w, CodePoseNotMatched, fmt.Sprintf("No %s frame matched the requested pose", frameErr.Pose), http.StatusUnprocessableEntity, map[string]interface{}{"pose": frameErr.Pose})
+		case errors.As(err, &frameErr):
+			details := map[string]interface{}{"pose": frameErr.Pose, "frame": frameErr.Index + 1}
+			if !respondWithCoreError(w, err, details) {
+				respondWithCode(w, CodeInvalidImage, fmt.Sprintf("Failed to process %s frame %d", frameErr.Pose, frameErr.Index+1), http.StatusUnprocessableEntity, details)
+			}
+		default:
+			respondWithCode(w, CodeInvalidImage, "Failed to process enrollment frames", http.StatusUnprocessableEntity, nil)
+		}
+		return nil, err
+	}
+
+	return samples, nil
+}
+
+// livenessEnrollmentSample runs the /verify_user identity and liveness checks
+// over the frame sequence and enrolls its best quality frame, among the
+// frontal ones when a frontal pose is required. Like enrollmentSamples it
+// writes the error response itself.
+func livenessEnrollmentSample(ctx context.Context, w http.ResponseWriter, policy tenants.Policy, frameImages [][]byte) ([]core.EnrollmentSample, error) {
+	if len(frameImages) != policy.LivenessFrameCount {
+		respondWithError(w, fmt.Sprintf("Exactly %d frames are required", policy.LivenessFrameCount), http.StatusBadRequest)
+		return nil, core.ErrNotLive
 	}
 
-	query := `
-		INSERT INTO users (
-			email,
-			first_name,
-			last_name,
-			facial_image
-		) VALUES ($1, $2, $3, $4
-		) RETURNING id`
-	var userID int
-	err = db.DB.QueryRow(
-		query,
-		thisRequest.Email,
-		thisRequest.FirstName,
-		thisRequest.LastName,
-		uploadResult.SecureURL,
-	).Scan(&userID)
+	decoded, frames, err := decodeLivenessFrames(ctx, w, frameImages)
 	if err != nil {
-		if dbError, ok := err.(*pq.Error); ok && dbError.Code.Name() == "unique_violation" {
-			respondWithError(w, "Email already exists", http.StatusConflict)
-			return
+		return nil, err
+	}
+
+	if _, err := checkLiveness(ctx, frames, policy); err != nil {
+		respondWithCoreError(w, err, nil)
+		return nil, err
+	}
+
+	var best *core.EnrollmentSample
+	turned := false
+	for _, frame := range decoded {
+		pose := poseOf(frame.Face)
+		if !frontalEnough(pose) {
+			turned = true
+			continue
 		}
-		respondWithError(w, "Failed to register user: "+err.Error(), http.StatusInternalServerError)
-		return
+		quality, err := core.AssessQualityJPEG(frame.Image, frame.Face.Rectangle)
+		if err != nil {
+			continue
+		}
+		if best == nil || quality.Score > best.Quality.Score {
+			best = &core.EnrollmentSample{
+				Pose:    core.PoseFront,
+				Image:   frame.Image,
+				Face:    *frame.Face,
+				Quality: quality,
+			}
+			if pose != nil {
+				best.HeadPose = *pose
+			}
+		}
+	}
+	if best == nil && turned {
+		respondWithCode(w, CodePoseNotMatched, "No frame shows the face looking straight at the camera", http.StatusUnprocessableEntity, nil)
+		return nil, core.ErrPoseNotMatched
+	}
+	if best == nil {
+		respondWithCode(w, CodeInvalidImage, "Failed to process frames", http.StatusUnprocessableEntity, nil)
+		return nil, core.ErrDecodingImage
 	}
 
-	respondWithJSON(w, http.StatusCreated, map[string]string{"message": "Registration successful!"})
+	return []core.EnrollmentSample{*best}, nil
+}
+
+// frontalEnough reports whether pose meets the frontal pose the enrollment
+// configuration may require. A face without landmarks has no pose, and does
+// not.
+func frontalEnough(pose *core.Pose) bool {
+	enrollment := config.Cfg.Enrollment
+	if !enrollment.RequireFrontalPose {
+		return true
+	}
+	return pose != nil && pose.IsFrontal(enrollment.MaxYaw, enrollment.MaxPitch, enrollment.MaxRoll)
+}
+
+func enrollmentLimits() core.PoseLimits {
+	enrollment := config.Cfg.Enrollment
+	return core.PoseLimits{
+		MaxYaw:     enrollment.MaxYaw,
+		MaxPitch:   enrollment.MaxPitch,
+		MaxRoll:    enrollment.MaxRoll,
+		MinTurnYaw: enrollment.MinTurnYaw,
+		MaxTurnYaw: enrollment.MaxTurnYaw,
+	}
 }
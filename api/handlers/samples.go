@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/Adedunmol/face-widget/api/db"
+	"github.com/Adedunmol/face-widget/api/models"
+	"github.com/Adedunmol/face-widget/api/storage"
+	"github.com/Adedunmol/face-widget/api/tenants"
+	"github.com/Adedunmol/face-widget/core"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// uploadSamples stores every enrollment image in the folder of the tenant
+// and returns the storage references in the same order as samples.
+func uploadSamples(ctx context.Context, tenant *tenants.Tenant, samples []core.EnrollmentSample) ([]string, error) {
+	refs := make([]string, 0, len(samples))
+	for _, sample := range samples {
+		key := tenant.StorageFolder() + "/" + uuid.NewString() + ".jpg"
+		ref, err := storage.Blobs.Put(ctx, key, sample.Image, "image/jpeg")
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+
+	return refs, nil
+}
+
+// insertUserWithSamples creates the user and its enrollment samples in one
+// transaction. The first sample's image becomes the user's facial_image.
+func insertUserWithSamples(ctx context.Context, tenantID int, thisRequest models.RegisterPayload, samples []core.EnrollmentSample, imageRefs []string) (int, error) {
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO users (
+			tenant_id,
+			email,
+			first_name,
+			last_name,
+			facial_image
+		) VALUES ($1, $2, $3, $4, $5
+		) RETURNING id`
+	var userID int
+	err = tx.QueryRowContext(
+		ctx,
+		query,
+		tenantID,
+		thisRequest.Email,
+		thisRequest.FirstName,
+		thisRequest.LastName,
+		imageRefs[0],
+	).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := insertSamples(ctx, tx, tenantID, userID, samples, imageRefs); err != nil {
+		return 0, err
+	}
+
+	return userID, tx.Commit()
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func insertSamples(ctx context.Context, tx execer, tenantID, userID int, samples []core.EnrollmentSample, imageRefs []string) error {
+	query := `
+		INSERT INTO face_samples (
+			tenant_id,
+			user_id,
+			pose,
+			image_url,
+			descriptor,
+			quality
+		) VALUES ($1, $2, $3, $4, $5, $6)`
+	for i, sample := range samples {
+		_, err := tx.ExecContext(
+			ctx,
+			query,
+			tenantID,
+			userID,
+			string(sample.Pose),
+			imageRefs[i],
+			pq.Float32Array(sample.Face.Descriptor[:]),
+			sample.Quality.Score,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadGallery reads every enrollment descriptor of a tenant together with the
+// users they belong to.
+func loadGallery(ctx context.Context, tenantID int) ([]core.Identity, map[int]models.User, error) {
+	query := `
+		SELECT
+			u.id,
+			u.email,
+			u.first_name,
+			u.last_name,
+			s.descriptor
+		FROM face_samples s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.tenant_id = $1`
+	rows, err := db.DB.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var gallery []core.Identity
+	users := make(map[int]models.User)
+	for rows.Next() {
+		var user models.User
+		var descriptor pq.Float32Array
+		if err := rows.Scan(&user.ID, &user.Email, &user.FirstName, &user.LastName, &descriptor); err != nil {
+			return nil, nil, err
+		}
+
+		identity := core.Identity{UserID: user.ID}
+		copy(identity.Descriptor[:], descriptor)
+		gallery = append(gallery, identity)
+		users[user.ID] = user
+	}
+
+	return gallery, users, rows.Err()
+}
+
+// deleteImages removes the stored images behind the given references.
+// Failures are logged rather than returned: the rows pointing at them are
+// already gone.
+func deleteImages(ctx context.Context, imageRefs []string) {
+	seen := map[string]bool{}
+	for _, ref := range imageRefs {
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+
+		if err := storage.Blobs.Delete(ctx, ref); err != nil {
+			slog.ErrorContext(ctx, "Failed to delete image", "ref", ref, "error", err)
+		}
+	}
+}
+
+// userImageRefs lists every stored image of a user: the base image and the
+// enrollment samples.
+func userImageRefs(ctx context.Context, q queryer, userID int) ([]string, error) {
+	query := `
+		SELECT facial_image FROM users WHERE id = $1
+		UNION
+		SELECT image_url FROM face_samples WHERE user_id = $1`
+	rows, err := q.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refs []string
+	for rows.Next() {
+		var imageRef string
+		if err := rows.Scan(&imageRef); err != nil {
+			return nil, err
+		}
+		refs = append(refs, imageRef)
+	}
+	return refs, rows.Err()
+}
+
+// replaceSamples swaps a user's enrollment samples and base image for new
+// ones and returns the references of the images that are no longer used.
+func replaceSamples(ctx context.Context, tenantID, userID int, samples []core.EnrollmentSample, imageRefs []string) ([]string, error) {
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	oldRefs, err := userImageRefs(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM face_samples WHERE user_id = $1`, userID); err != nil {
+		return nil, err
+	}
+	if err := insertSamples(ctx, tx, tenantID, userID, samples, imageRefs); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET facial_image = $1 WHERE id = $2`, imageRefs[0], userID); err != nil {
+		return nil, err
+	}
+
+	return oldRefs, tx.Commit()
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/Adedunmol/face-widget/api/models"
+	"github.com/Adedunmol/face-widget/api/tenants"
+)
+
+func tenantSettings(tenant *tenants.Tenant) models.TenantSettings {
+	return models.TenantSettings{
+		TenantID: tenant.ID,
+		Name:     tenant.Name,
+		Settings: tenant.Settings,
+		Policy:   tenant.Policy(),
+	}
+}
+
+func GetSettings(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, tenantSettings(tenantOf(r)))
+}
+
+// UpdateSettings replaces the overrides of the tenant. Fields left out fall
+// back to the deployment configuration.
+func UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var settings tenants.Settings
+	if err := readJSON(r, &settings); err != nil {
+		respondWithPayloadError(w, err)
+		return
+	}
+
+	tenant := *tenantOf(r)
+	err := tenants.UpdateSettings(r.Context(), tenant.ID, settings)
+	if errors.Is(err, tenants.ErrInvalid) {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to update tenant settings", "error", err)
+		respondWithError(w, "Failed to update settings", http.StatusInternalServerError)
+		return
+	}
+
+	tenant.Settings = settings
+	respondWithJSON(w, http.StatusOK, tenantSettings(&tenant))
+}
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/db"
+	"github.com/Adedunmol/face-widget/api/models"
+	"github.com/Adedunmol/face-widget/api/ratelimit"
+	"github.com/Adedunmol/face-widget/api/storage"
+	"github.com/Adedunmol/face-widget/core"
+
+	"github.com/Kagami/go-face"
+	"github.com/lib/pq"
+)
+
+// sampleURLTTL is how long the image URLs of the face samples listed to
+// admins stay valid.
+const sampleURLTTL = 15 * time.Minute
+
+// userID parses the {id} path segment, answering 404 when it is not a valid
+// id.
+func userID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		respondWithCode(w, CodeUserNotFound, "User not found", http.StatusNotFound, nil)
+		return 0, false
+	}
+	return id, true
+}
+
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if !isAdmin(r) {
+		respondWithCode(w, CodeInvalidCredentials, "Admin credentials required", http.StatusUnauthorized, nil)
+		return false
+	}
+	return true
+}
+
+func loadUser(ctx context.Context, tenantID, id int) (models.User, string, error) {
+	query := `
+		SELECT
+			id,
+			email,
+			first_name,
+			last_name,
+			facial_image
+		FROM users
+		WHERE id = $1 AND tenant_id = $2`
+	var user models.User
+	var baseImageRef string
+	err := db.DB.QueryRowContext(ctx, query, id, tenantID).Scan(
+		&user.ID,
+		&user.Email,
+		&user.FirstName,
+		&user.LastName,
+		&baseImageRef,
+	)
+	return user, baseImageRef, err
+}
+
+func GetUser(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	user, _, err := loadUser(ctx, tenantOf(r).ID, id)
+	if err == sql.ErrNoRows {
+		respondWithCode(w, CodeUserNotFound, "User not found", http.StatusNotFound, nil)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to look up user", "error", err)
+		respondWithError(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	query := `
+		SELECT
+			id,
+			pose,
+			quality,
+			image_url,
+			created_at
+		FROM face_samples
+		WHERE user_id = $1
+		ORDER BY id`
+	rows, err := db.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to load face samples", "error", err)
+		respondWithError(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	details := models.UserDetails{User: user, Samples: []models.FaceSample{}}
+	for rows.Next() {
+		var sample models.FaceSample
+		var imageRef string
+		if err := rows.Scan(&sample.ID, &sample.Pose, &sample.Quality, &imageRef, &sample.CreatedAt); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to load face samples", "error", err)
+			respondWithError(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+		sample.ImageURL, err = storage.Blobs.SignedURL(ctx, imageRef, sampleURLTTL)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Failed to sign sample image URL", "sample_id", sample.ID, "error", err)
+		}
+		details.Samples = append(details.Samples, sample)
+	}
+
+	respondWithJSON(w, http.StatusOK, details)
+}
+
+func UpdateUser(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+
+	var thisRequest models.UpdateUserPayload
+	if err := readJSON(r, &thisRequest); err != nil {
+		respondWithPayloadError(w, err)
+		return
+	}
+
+	for _, field := range []*string{thisRequest.Email, thisRequest.FirstName, thisRequest.LastName} {
+		if field != nil && strings.TrimSpace(*field) == "" {
+			respondWithError(w, "Fields cannot be empty", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// COALESCE keeps the current value of every field left out of the body.
+	query := `
+		UPDATE users SET
+			email = COALESCE($2, email),
+			first_name = COALESCE($3, first_name),
+			last_name = COALESCE($4, last_name)
+		WHERE id = $1 AND tenant_id = $5
+		RETURNING id, email, first_name, last_name`
+	var user models.User
+	err := db.DB.QueryRowContext(
+		r.Context(),
+		query,
+		id,
+		thisRequest.Email,
+		thisRequest.FirstName,
+		thisRequest.LastName,
+		tenantOf(r).ID,
+	).Scan(&user.ID, &user.Email, &user.FirstName, &user.LastName)
+	if err == sql.ErrNoRows {
+		respondWithCode(w, CodeUserNotFound, "User not found", http.StatusNotFound, nil)
+		return
+	}
+	if err != nil {
+		if dbError, ok := err.(*pq.Error); ok && dbError.Code.Name() == "unique_violation" {
+			respondWithCode(w, CodeEmailExists, "Email already exists", http.StatusConflict, nil)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to update user", "error", err)
+		respondWithError(w, "Failed to update user", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+// DeleteUser removes the user, its enrollment samples and every stored image.
+func DeleteUser(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to delete user", "error", err)
+		respondWithError(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	imageRefs, err := userImageRefs(ctx, tx, id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to delete user", "error", err)
+		respondWithError(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+
+	// The face samples go with the user, the foreign key cascades.
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1 AND tenant_id = $2`, id, tenantOf(r).ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to delete user", "error", err)
+		respondWithError(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		respondWithCode(w, CodeUserNotFound, "User not found", http.StatusNotFound, nil)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to delete user", "error", err)
+		respondWithError(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+
+	deleteImages(context.WithoutCancel(r.Context()), imageRefs)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReplaceUserFace re-enrolls a user's face. The caller must either be an
+// admin or prove to be the user, live: the new face then has to come as
+// liveness frames that match the face currently enrolled. A photo of the
+// user is not enough.
+func ReplaceUserFace(w http.ResponseWriter, r *http.Request) {
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+
+	thisRequest, err := readReplaceFacePayload(r)
+	if err != nil {
+		respondWithPayloadError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	tenant := tenantOf(r)
+	policy := tenant.Policy()
+	_, baseImageRef, err := loadUser(ctx, tenant.ID, id)
+	if err == sql.ErrNoRows {
+		respondWithCode(w, CodeUserNotFound, "User not found", http.StatusNotFound, nil)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to look up user", "error", err)
+		respondWithError(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	admin := isAdmin(r)
+	if !admin && len(thisRequest.FrameImages) == 0 {
+		respondWithCode(w, CodeInvalidCredentials, "Liveness frames or admin credentials required", http.StatusUnauthorized, nil)
+		return
+	}
+
+	var reservation *ratelimit.Reservation
+	if !admin {
+		if reservation = reserveAttempt(w, r, verificationAttempt(r, id)); reservation == nil {
+			return
+		}
+	}
+
+	// Liveness frames take precedence, so a non-admin sample is live.
+	samples, err := enrollmentSamples(r.Context(), w, policy, thisRequest.FacePayload)
+	if err != nil {
+		return
+	}
+
+	if !admin {
+		if !verifyCurrentFace(w, r, baseImageRef, &samples[0].Face, policy.MatchThreshold) {
+			return
+		}
+		attemptSucceeded(r, reservation)
+	}
+
+	imageRefs, err := uploadSamples(ctx, tenant, samples)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to upload file", "error", err)
+		respondWithCode(w, CodeStorageError, "Error uploading image", http.StatusInternalServerError, nil)
+		return
+	}
+
+	oldRefs, err := replaceSamples(ctx, tenant.ID, id, samples, imageRefs)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to replace face", "error", err)
+		deleteImages(context.WithoutCancel(r.Context()), imageRefs)
+		respondWithError(w, "Failed to replace face", http.StatusInternalServerError)
+		return
+	}
+
+	deleteImages(context.WithoutCancel(r.Context()), oldRefs)
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Face replaced successfully!"})
+}
+
+// verifyCurrentFace checks candidate against the user's enrolled base image
+// and writes the error response itself when it does not match.
+func verifyCurrentFace(w http.ResponseWriter, r *http.Request, baseImageRef string, candidate *face.Face, threshold float64) bool {
+	baseImage, err := storage.Blobs.Get(r.Context(), baseImageRef)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to download base image", "error", err)
+		respondWithCode(w, CodeStorageError, "Error downloading base image", http.StatusInternalServerError, nil)
+		return false
+	}
+
+	known, err := core.CheckFaceData(baseImage)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to find a face on the base image", "error", err)
+		respondWithError(w, "Failed to verify user", http.StatusInternalServerError)
+		return false
+	}
+
+	if _, err := core.CompareFaces(known, candidate, threshold); err != nil {
+		respondWithCoreError(w, err, nil)
+		return false
+	}
+	return true
+}
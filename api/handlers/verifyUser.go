@@ -2,18 +2,17 @@ package handlers
 
 import (
 	"database/sql"
-	"encoding/base64"
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
-	"time"
 
+	"github.com/Adedunmol/face-widget/api/audit"
 	"github.com/Adedunmol/face-widget/api/db"
 	"github.com/Adedunmol/face-widget/api/models"
+	"github.com/Adedunmol/face-widget/api/storage"
+	"github.com/Adedunmol/face-widget/api/token"
+	"github.com/Adedunmol/face-widget/api/webhooks"
 	"github.com/Adedunmol/face-widget/core"
+	"github.com/Adedunmol/face-widget/tracing"
 )
 
 func VerifyUser(w http.ResponseWriter, r *http.Request) {
@@ -22,24 +21,26 @@ func VerifyUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		respondWithError(w, "Error reading request body", http.StatusBadRequest)
-		return
-	}
+	w, trail := startAudit(w, r, audit.ActionVerify, token.MethodSingleImage)
+	defer trail.record(r)
 
-	var thisRequest models.VerifyUserPayload
-	err = json.Unmarshal(body, &thisRequest)
+	_, span := tracing.Start(r.Context(), "decode_payload")
+	thisRequest, err := readVerifyUserPayload(r)
+	span.RecordError(err)
+	span.End()
 	if err != nil {
-		respondWithError(w, "Invalid request payload", http.StatusBadRequest)
+		respondWithPayloadError(w, err)
 		return
 	}
 
-	if thisRequest.Email == "" || thisRequest.EncodedImage == "" {
+	if thisRequest.Email == "" || len(thisRequest.Image) == 0 {
 		respondWithError(w, "All fields are required", http.StatusBadRequest)
 		return
 	}
 
+	tenant := tenantOf(r)
+	policy := tenant.Policy()
+
 	query := `
 		SELECT
 			id,
@@ -47,107 +48,108 @@ func VerifyUser(w http.ResponseWriter, r *http.Request) {
 			last_name,
 			facial_image
 		FROM users
-		WHERE email = $1`
+		WHERE email = $1 AND tenant_id = $2`
 	var thisUser models.User
-	var baseImageURL string
-	err = db.DB.QueryRow(query, thisRequest.Email).Scan(
+	var baseImageRef string
+	err = db.DB.QueryRowContext(r.Context(), query, thisRequest.Email, tenant.ID).Scan(
 		&thisUser.ID,
 		&thisUser.FirstName,
 		&thisUser.LastName,
-		&baseImageURL,
+		&baseImageRef,
 	)
-	if err == sql.ErrNoRows {
-		respondWithError(w, "User account doesn't exist", http.StatusUnauthorized)
-		return
-	}
-	if err != nil {
-		respondWithError(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+	if err != nil && err != sql.ErrNoRows {
+		slog.ErrorContext(r.Context(), "Failed to look up user", "error", err)
+		respondWithError(w, "Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	thisUser.Email = thisRequest.Email
+	trail.setUser(thisUser.ID)
 
-	// Get the data from the URL
-	resp, err := http.Get(baseImageURL)
-	if err != nil {
-		log.Printf("Failed to download file from URL: %w", err)
-		respondWithError(w, "Error downloading baseImage from Cloudinary", http.StatusInternalServerError)
+	// Unknown emails count against the IP and API key limits, so they
+	// cannot be probed freely either.
+	reservation := reserveAttempt(w, r, verificationAttempt(r, thisUser.ID))
+	if reservation == nil {
 		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Unexpected status code: %d", resp.StatusCode)
-		respondWithError(w, "Error downloading baseImage from Cloudinary", http.StatusInternalServerError)
+	if err == sql.ErrNoRows {
+		data := webhooks.VerificationData{
+			Method: token.MethodSingleImage,
+			Reason: CodeUserNotFound,
+		}
+		trail.observe(data)
+		publishEvent(r, webhooks.EventVerificationFailed, data)
+		respondVerificationFailed(w)
 		return
 	}
 
-	// 1. Decode the Base64 string into bytes.
-	decodedData, err := base64.StdEncoding.DecodeString(thisRequest.EncodedImage)
+	thisUser.Email = thisRequest.Email
+
+	baseImage, err := storage.Blobs.Get(r.Context(), baseImageRef)
 	if err != nil {
-		respondWithError(w, "Invalid Base64 string", http.StatusBadRequest)
+		slog.ErrorContext(r.Context(), "Failed to download base image", "error", err)
+		respondWithCode(w, CodeStorageError, "Error downloading base image", http.StatusInternalServerError, nil)
 		return
 	}
 
-	// 2. Detect the content type (image format) from the decoded bytes.
+	decodedData := thisRequest.Image
+
+	// Detect the content type (image format) from the decoded bytes.
 	fileType := http.DetectContentType(decodedData)
 	if fileType != "image/jpeg" {
-		respondWithError(w, "Unsupported image format", http.StatusBadRequest)
+		respondWithCode(w, CodeUnsupportedFormat, "Unsupported image format", http.StatusBadRequest, nil)
 		return
 	}
 
-	baseImageFilename := fmt.Sprintf(
-		"%d_%s%s%s%s",
-		time.Now().Unix(),
-		thisUser.FirstName,
-		thisUser.LastName,
-		"BaseImage",
-		".jpg",
-	)
-	// 3. Create a unique filename for the new file
-	verificationImageFilename := fmt.Sprintf(
-		"%d_%s%s%s%s",
-		time.Now().Unix(),
-		thisUser.FirstName,
-		thisUser.LastName,
-		"VerificationImage",
-		".jpg",
-	)
-
-	baseFilepath := fmt.Sprintf("./images/%s", baseImageFilename)
-	verificationFilepath := fmt.Sprintf("./images/%s", verificationImageFilename)
-
-	baseFile, err := os.Create(baseFilepath)
-	if err != nil {
-		log.Printf("Failed to create temp file: %w", err)
-		respondWithError(w, "Error creating file", http.StatusInternalServerError)
+	comparison, err := core.CompareImages(r.Context(), baseImage, decodedData, policy.MatchThreshold)
+	observeVerification(token.MethodSingleImage, comparison, err)
+	if err == core.ErrNoMatch {
+		data := webhooks.VerificationData{
+			UserID:   thisUser.ID,
+			Method:   token.MethodSingleImage,
+			Reason:   CodeNoMatch,
+			Distance: &comparison.Distance,
+		}
+		trail.observe(data)
+		publishEvent(r, webhooks.EventVerificationFailed, data)
+		respondVerificationFailed(w)
 		return
-	}
-	defer baseFile.Close()
-
-	// 4. Save the decoded data to a new file
-	if _, err := io.Copy(baseFile, resp.Body); err != nil {
-		os.Remove(baseFilepath)
-		respondWithError(w, "Failed to save baseImage file"+err.Error(), http.StatusInternalServerError)
+	} else if err != nil {
+		if respondWithCoreError(w, err, nil) {
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to verify user", "error", err)
+		respondWithError(w, "Failed to verify user", http.StatusInternalServerError)
 		return
 	}
 
-	if err := os.WriteFile(verificationFilepath, decodedData, 0644); err != nil {
-		os.Remove(baseFilepath)
-		respondWithError(w, "Failed to save verificationImage file"+err.Error(), http.StatusInternalServerError)
+	signed, err := token.IssueVerification(
+		tenant.ID,
+		thisUser.ID,
+		token.MethodSingleImage,
+		token.Scores{Distance: comparison.Distance},
+		thisRequest.Nonce,
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to sign token", "error", err)
+		respondWithError(w, "Failed to verify user", http.StatusInternalServerError)
 		return
 	}
 
-	if err := core.CompareImages(baseImageFilename, verificationImageFilename); err == core.ErrNoMatch {
-		os.Remove(baseFilepath)
-		respondWithError(w, "Invalid credentials", http.StatusUnauthorized)
-		return
-	} else if err != nil {
-		os.Remove(baseFilepath)
-		os.Remove(verificationFilepath)
-		respondWithError(w, "Failed to verify user: "+err.Error(), http.StatusInternalServerError)
-		return
+	attemptSucceeded(r, reservation)
+	data := webhooks.VerificationData{
+		UserID:   thisUser.ID,
+		Method:   token.MethodSingleImage,
+		Distance: &comparison.Distance,
 	}
-
-	respondWithJSON(w, http.StatusOK, thisUser)
+	trail.observe(data)
+	publishEvent(r, webhooks.EventVerificationSucceeded, data)
+
+	respondWithJSON(w, http.StatusOK, models.VerificationResponse{
+		User: thisUser,
+		Diagnostics: models.VerificationDiagnostics{
+			Distance: comparison.Distance,
+			Pose:     poseOf(comparison.Candidate),
+		},
+		Token: signed,
+	})
 }
@@ -2,18 +2,17 @@ package handlers
 
 import (
 	"database/sql"
-	"encoding/base64"
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
-	"time"
 
+	"github.com/Adedunmol/face-widget/api/audit"
 	"github.com/Adedunmol/face-widget/api/db"
 	"github.com/Adedunmol/face-widget/api/models"
+	"github.com/Adedunmol/face-widget/api/storage"
+	"github.com/Adedunmol/face-widget/api/token"
+	"github.com/Adedunmol/face-widget/api/webhooks"
 	"github.com/Adedunmol/face-widget/core"
+	"github.com/Adedunmol/face-widget/tracing"
 )
 
 func NewVerifyUser(w http.ResponseWriter, r *http.Request) {
@@ -22,21 +21,26 @@ func NewVerifyUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		respondWithError(w, "Error reading request body", http.StatusBadRequest)
-		return
-	}
+	w, trail := startAudit(w, r, audit.ActionVerifyUser, token.MethodLiveness)
+	defer trail.record(r)
 
-	var thisRequest models.NewVerifyUserPayload
-	err = json.Unmarshal(body, &thisRequest)
+	_, span := tracing.Start(r.Context(), "decode_payload")
+	thisRequest, err := readNewVerifyUserPayload(r)
+	span.RecordError(err)
+	span.End()
 	if err != nil {
-		respondWithError(w, "Invalid request payload", http.StatusBadRequest)
+		respondWithPayloadError(w, err)
 		return
 	}
 
-	if thisRequest.Email == "" || len(thisRequest.Frames) != 5 {
-		log.Println("Email missing or frames < 5")
+	tenant := tenantOf(r)
+	policy := tenant.Policy()
+
+	if thisRequest.Email == "" || len(thisRequest.FrameImages) != policy.LivenessFrameCount {
+		slog.InfoContext(r.Context(), "Invalid verification request",
+			"frames", len(thisRequest.FrameImages),
+			"expected_frames", policy.LivenessFrameCount,
+		)
 		respondWithError(w, "Request fields invalid", http.StatusBadRequest)
 		return
 	}
@@ -48,150 +52,140 @@ func NewVerifyUser(w http.ResponseWriter, r *http.Request) {
 			last_name,
 			facial_image
 		FROM users
-		WHERE email = $1`
+		WHERE email = $1 AND tenant_id = $2`
 	var thisUser models.User
-	var baseImageURL string
-	err = db.DB.QueryRow(query, thisRequest.Email).Scan(
+	var baseImageRef string
+	err = db.DB.QueryRowContext(r.Context(), query, thisRequest.Email, tenant.ID).Scan(
 		&thisUser.ID,
 		&thisUser.FirstName,
 		&thisUser.LastName,
-		&baseImageURL,
+		&baseImageRef,
 	)
-	if err == sql.ErrNoRows {
-		respondWithError(w, "User account doesn't exist", http.StatusUnauthorized)
-		return
-	}
-	if err != nil {
-		respondWithError(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+	if err != nil && err != sql.ErrNoRows {
+		slog.ErrorContext(r.Context(), "Failed to look up user", "error", err)
+		respondWithError(w, "Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	thisUser.Email = thisRequest.Email
-
-	var frames []core.FrameData
-	var mainDecoded []byte
-	for i, frame := range thisRequest.Frames {
-		decodedData, err := base64.StdEncoding.DecodeString(frame)
-		if err != nil {
-			respondWithError(w, "Invalid base64 string for frame "+string(i+1), http.StatusBadRequest)
-			return
-		}
+	trail.setUser(thisUser.ID)
 
-		fileType := http.DetectContentType(decodedData)
-		if fileType != "image/jpeg" {
-			respondWithError(w, "Unsupported image format for frame "+string(i+1), http.StatusBadRequest)
-			return
-		}
-
-		detected, err := core.Rec.RecognizeSingle(decodedData)
-		if detected == nil {
-			log.Println("No face found on frame", i+1)
-			respondWithError(w, "Failed to find a face", http.StatusUnprocessableEntity)
-			return
-		}
-
-		if i == 0 {
-			mainDecoded = decodedData
-		}
-
-		frames = append(frames, core.FrameData{
-			Descriptor: detected.Descriptor,
-			Rect:       detected.Rectangle,
-		})
-	}
-
-	if len(frames) < 5 {
-		log.Println("Valid frames < 5")
-		respondWithError(w, "Failed to verify face", http.StatusUnprocessableEntity)
+	// Unknown emails count against the IP and API key limits, so they
+	// cannot be probed freely either.
+	reservation := reserveAttempt(w, r, verificationAttempt(r, thisUser.ID))
+	if reservation == nil {
 		return
 	}
-
-	// 1. Check for same identity
-	samePerson := core.IsSamePerson(core.Rec, frames)
-	log.Println("same person: ", samePerson)
-	if !samePerson {
-		respondWithError(w, "Invalid credentials", http.StatusUnauthorized)
+	if err == sql.ErrNoRows {
+		data := webhooks.VerificationData{
+			Method: token.MethodLiveness,
+			Reason: CodeUserNotFound,
+		}
+		trail.observe(data)
+		publishEvent(r, webhooks.EventVerificationFailed, data)
+		respondVerificationFailed(w)
 		return
 	}
 
-	// 2. Check for movement
-	rectMotion := core.ComputeRectangleMotion(frames)
-	descriptorShift := core.ComputeDescriptorShift(frames)
-	log.Printf("rectMotion: %v, descriptorShift: %v\n", rectMotion, descriptorShift)
-
-	// Thresholds (tune by experimentation)
-	live := rectMotion <= 10 && descriptorShift >= 0.07
-	if !live {
-		respondWithError(w, "Invalid credentials", http.StatusUnauthorized)
-		return
-	}
+	thisUser.Email = thisRequest.Email
 
-	// Get the data from the URL
-	resp, err := http.Get(baseImageURL)
+	decoded, frames, err := decodeLivenessFrames(r.Context(), w, thisRequest.FrameImages)
 	if err != nil {
-		log.Printf("Failed to download file from URL: %w", err)
-		respondWithError(w, "Error downloading baseImage from Cloudinary", http.StatusInternalServerError)
 		return
 	}
-	defer resp.Body.Close()
+	mainDecoded := decoded[0].Image
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Unexpected status code: %d", resp.StatusCode)
-		respondWithError(w, "Error downloading baseImage from Cloudinary", http.StatusInternalServerError)
-		return
+	var framePoses []*core.Pose
+	for _, frame := range decoded {
+		framePoses = append(framePoses, poseOf(frame.Face))
 	}
 
-	baseImageFilename := fmt.Sprintf(
-		"%d_%s%s%s%s",
-		time.Now().Unix(),
-		thisUser.FirstName,
-		thisUser.LastName,
-		"BaseImage",
-		".jpg",
-	)
-	// 3. Create a unique filename for the new file
-	verificationImageFilename := fmt.Sprintf(
-		"%d_%s%s%s%s",
-		time.Now().Unix(),
-		thisUser.FirstName,
-		thisUser.LastName,
-		"VerificationImage",
-		".jpg",
-	)
-
-	baseFilepath := fmt.Sprintf("./images/%s", baseImageFilename)
-	verificationFilepath := fmt.Sprintf("./images/%s", verificationImageFilename)
-
-	baseFile, err := os.Create(baseFilepath)
+	liveness, err := checkLiveness(r.Context(), frames, policy)
 	if err != nil {
-		log.Printf("Failed to create temp file: %w", err)
-		respondWithError(w, "Error creating file", http.StatusInternalServerError)
-		return
-	}
-	defer baseFile.Close()
-
-	if _, err := io.Copy(baseFile, resp.Body); err != nil {
-		os.Remove(baseFilepath)
-		respondWithError(w, "Failed to save baseImage file"+err.Error(), http.StatusInternalServerError)
+		data := webhooks.VerificationData{
+			UserID: thisUser.ID,
+			Method: token.MethodLiveness,
+			Reason: CodeLivenessFailed,
+		}
+		if err == core.ErrNotSamePerson {
+			data.Reason = CodeIdentityMismatch
+		} else {
+			data.RectMotion = &liveness.RectMotion
+			data.DescriptorShift = &liveness.DescriptorShift
+		}
+		trail.observe(data)
+		publishEvent(r, webhooks.EventLivenessFailed, data)
+		respondVerificationFailed(w)
 		return
 	}
 
-	if err := os.WriteFile(verificationFilepath, mainDecoded, 0644); err != nil {
-		os.Remove(baseFilepath)
-		respondWithError(w, "Failed to save verificationImage file"+err.Error(), http.StatusInternalServerError)
+	baseImage, err := storage.Blobs.Get(r.Context(), baseImageRef)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to download base image", "error", err)
+		respondWithCode(w, CodeStorageError, "Error downloading base image", http.StatusInternalServerError, nil)
 		return
 	}
 
-	if err := core.CompareImages(baseImageFilename, verificationImageFilename); err == core.ErrNoMatch {
-		os.Remove(baseFilepath)
-		respondWithError(w, "Invalid credentials", http.StatusUnauthorized)
+	comparison, err := core.CompareImages(r.Context(), baseImage, mainDecoded, policy.MatchThreshold)
+	observeVerification(token.MethodLiveness, comparison, err)
+	if err == core.ErrNoMatch {
+		data := webhooks.VerificationData{
+			UserID:          thisUser.ID,
+			Method:          token.MethodLiveness,
+			Reason:          CodeNoMatch,
+			Distance:        &comparison.Distance,
+			RectMotion:      &liveness.RectMotion,
+			DescriptorShift: &liveness.DescriptorShift,
+		}
+		trail.observe(data)
+		publishEvent(r, webhooks.EventVerificationFailed, data)
+		respondVerificationFailed(w)
 		return
 	} else if err != nil {
-		os.Remove(baseFilepath)
-		os.Remove(verificationFilepath)
-		respondWithError(w, "Failed to verify user: "+err.Error(), http.StatusInternalServerError)
+		if respondWithCoreError(w, err, nil) {
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to verify user", "error", err)
+		respondWithError(w, "Failed to verify user", http.StatusInternalServerError)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, thisUser)
+	signed, err := token.IssueVerification(
+		tenant.ID,
+		thisUser.ID,
+		token.MethodLiveness,
+		token.Scores{
+			Distance:        comparison.Distance,
+			RectMotion:      &liveness.RectMotion,
+			DescriptorShift: &liveness.DescriptorShift,
+		},
+		thisRequest.Nonce,
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to sign token", "error", err)
+		respondWithError(w, "Failed to verify user", http.StatusInternalServerError)
+		return
+	}
+
+	attemptSucceeded(r, reservation)
+	data := webhooks.VerificationData{
+		UserID:          thisUser.ID,
+		Method:          token.MethodLiveness,
+		Distance:        &comparison.Distance,
+		RectMotion:      &liveness.RectMotion,
+		DescriptorShift: &liveness.DescriptorShift,
+	}
+	trail.observe(data)
+	publishEvent(r, webhooks.EventVerificationSucceeded, data)
+
+	respondWithJSON(w, http.StatusOK, models.VerificationResponse{
+		User: thisUser,
+		Diagnostics: models.VerificationDiagnostics{
+			Distance:        comparison.Distance,
+			Pose:            poseOf(comparison.Candidate),
+			FramePoses:      framePoses,
+			RectMotion:      &liveness.RectMotion,
+			DescriptorShift: &liveness.DescriptorShift,
+		},
+		Token: signed,
+	})
 }
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Adedunmol/face-widget/api/models"
+	"github.com/Adedunmol/face-widget/api/webhooks"
+)
+
+// maxDeliveries bounds the deliveries listed at once.
+const maxDeliveries = 100
+
+func webhookModel(endpoint webhooks.Endpoint) models.Webhook {
+	return models.Webhook{
+		ID:        endpoint.ID,
+		URL:       endpoint.URL,
+		Events:    endpoint.Events,
+		CreatedAt: endpoint.CreatedAt,
+	}
+}
+
+func ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	endpoints, err := webhooks.ListEndpoints(r.Context(), tenantOf(r).ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to list webhooks", "error", err)
+		respondWithError(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]models.Webhook, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		response = append(response, webhookModel(endpoint))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// CreateWebhook returns the signing secret of the new endpoint. It is the
+// only time it is shown.
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var thisRequest models.CreateWebhookPayload
+	if err := readJSON(r, &thisRequest); err != nil {
+		respondWithPayloadError(w, err)
+		return
+	}
+
+	endpoint, secret, err := webhooks.CreateEndpoint(r.Context(), tenantOf(r).ID, thisRequest.URL, thisRequest.Events)
+	if errors.Is(err, webhooks.ErrInvalid) {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to create webhook", "error", err)
+		respondWithError(w, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, models.CreatedWebhook{Webhook: webhookModel(endpoint), Secret: secret})
+}
+
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err == nil {
+		err = webhooks.DeleteEndpoint(r.Context(), tenantOf(r).ID, id)
+	}
+	if err != nil {
+		if _, ok := err.(*strconv.NumError); ok || err == webhooks.ErrNotFound {
+			respondWithCode(w, CodeWebhookNotFound, "Webhook not found", http.StatusNotFound, nil)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to delete webhook", "error", err)
+		respondWithError(w, "Failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries lists the latest deliveries, filtered by the status
+// query parameter when it is set.
+func ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", webhooks.StatusPending, webhooks.StatusDelivered, webhooks.StatusFailed:
+	default:
+		respondWithError(w, "status must be pending, delivered or failed", http.StatusBadRequest)
+		return
+	}
+
+	deliveries, err := webhooks.ListDeliveries(r.Context(), tenantOf(r).ID, status, maxDeliveries)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to list webhook deliveries", "error", err)
+		respondWithError(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]models.WebhookDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		model := models.WebhookDelivery{
+			ID:             delivery.ID,
+			WebhookID:      delivery.EndpointID,
+			EventID:        delivery.EventID,
+			EventType:      delivery.EventType,
+			Status:         delivery.Status,
+			Attempts:       delivery.Attempts,
+			LastStatusCode: delivery.LastStatusCode,
+			LastError:      delivery.LastError,
+			CreatedAt:      delivery.CreatedAt,
+			DeliveredAt:    delivery.DeliveredAt,
+		}
+		if delivery.Status == webhooks.StatusPending {
+			model.NextAttemptAt = &delivery.NextAttemptAt
+		}
+		response = append(response, model)
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// ReplayWebhookDelivery sends a failed or delivered event again.
+func ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err == nil {
+		err = webhooks.Replay(r.Context(), tenantOf(r).ID, id)
+	}
+	if err != nil {
+		if _, ok := err.(*strconv.NumError); ok || err == webhooks.ErrNotFound {
+			respondWithCode(w, CodeDeliveryNotFound, "Delivery not found", http.StatusNotFound, nil)
+			return
+		}
+		if err == webhooks.ErrPending {
+			respondWithCode(w, CodeDeliveryPending, "Delivery is still pending", http.StatusConflict, nil)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to replay webhook delivery", "error", err)
+		respondWithError(w, "Failed to replay delivery", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// publishEvent queues a webhook event of the request's tenant. Failing to
+// queue it does not fail the request.
+func publishEvent(r *http.Request, eventType string, data interface{}) {
+	ctx := context.WithoutCancel(r.Context())
+	if err := webhooks.Publish(ctx, tenantOf(r).ID, eventType, data); err != nil {
+		slog.ErrorContext(ctx, "Failed to publish webhook event", "event", eventType, "error", err)
+	}
+}
@@ -0,0 +1,131 @@
+// Package health answers the liveness and readiness probes of the platform
+// the server runs on.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/config"
+	"github.com/Adedunmol/face-widget/api/db"
+	"github.com/Adedunmol/face-widget/api/storage"
+)
+
+const (
+	LivePath  = "/healthz"
+	ReadyPath = "/readyz"
+)
+
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+type Check struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type Report struct {
+	Status string           `json:"status"`
+	Checks map[string]Check `json:"checks"`
+}
+
+var checks = map[string]func(context.Context) error{
+	"database":   checkDatabase,
+	"recognizer": checkRecognizer,
+	"storage":    checkStorage,
+}
+
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+LivePath, Live)
+	mux.HandleFunc("GET "+ReadyPath, Ready)
+}
+
+// Live reports that the process is up and serving.
+func Live(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": StatusOK})
+}
+
+// Ready runs every check concurrently and answers 503 with the breakdown
+// when one of them fails.
+func Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), config.Cfg.Health.Timeout)
+	defer cancel()
+
+	report := Report{Status: StatusOK, Checks: map[string]Check{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, run := range checks {
+		wg.Add(1)
+		go func(name string, run func(context.Context) error) {
+			defer wg.Done()
+			check := runCheck(ctx, run)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = check
+			if check.Status == StatusFailed {
+				report.Status = StatusFailed
+			}
+		}(name, run)
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	respondWithJSON(w, status, report)
+}
+
+// runCheck runs a check, giving up when ctx expires even if the check does
+// not watch it.
+func runCheck(ctx context.Context, run func(context.Context) error) Check {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- run(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	check := Check{Status: StatusOK, Duration: time.Since(start).Round(time.Millisecond).String()}
+	if err != nil {
+		check.Status = StatusFailed
+		check.Error = err.Error()
+	}
+	return check
+}
+
+func checkDatabase(ctx context.Context) error {
+	if db.DB == nil {
+		return errors.New("not connected")
+	}
+	return db.DB.PingContext(ctx)
+}
+
+// checkStorage reaches the blob store, the only place images are written
+// since comparisons run in memory. The local backend writes and deletes a
+// file in its directory.
+func checkStorage(ctx context.Context) error {
+	if storage.Blobs == nil {
+		return errors.New("not configured")
+	}
+	return storage.Blobs.Ping(ctx)
+}
+
+func respondWithJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
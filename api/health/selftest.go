@@ -0,0 +1,55 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/config"
+	"github.com/Adedunmol/face-widget/core"
+)
+
+// The self-test runs the whole recognition pipeline, which takes a while,
+// so a passing result is reused for config.Cfg.Health.SelfTestInterval.
+var selfTest struct {
+	sync.Mutex
+	passedAt time.Time
+}
+
+// checkRecognizer detects the face of the self-test image. A missing image
+// fails the check: a loaded recognizer may still be unable to detect faces,
+// with the wrong models for one.
+func checkRecognizer(ctx context.Context) error {
+	if core.Rec == nil {
+		return errors.New("recognizer not loaded")
+	}
+
+	selfTest.Lock()
+	defer selfTest.Unlock()
+
+	if time.Since(selfTest.passedAt) < config.Cfg.Health.SelfTestInterval {
+		return nil
+	}
+
+	image, err := os.ReadFile(config.Cfg.Health.SelfTestImage)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no self-test image at %s", config.Cfg.Health.SelfTestImage)
+	}
+	if err != nil {
+		return err
+	}
+
+	faces, err := core.DetectFaces(image)
+	if err != nil {
+		return err
+	}
+	if len(faces) != 1 {
+		return fmt.Errorf("self-test image: expected 1 face, detected %d", len(faces))
+	}
+
+	selfTest.passedAt = time.Now()
+	return nil
+}
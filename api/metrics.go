@@ -0,0 +1,23 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/Adedunmol/face-widget/api/config"
+	"github.com/Adedunmol/face-widget/metrics"
+)
+
+// serveMetrics exposes the metrics to the scraper, which must send
+// METRICS_TOKEN as a bearer token when it is set.
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if token := config.Cfg.Metrics.Token; token != "" {
+		expected := []byte("Bearer " + token)
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	metrics.Handler(w, r)
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// AccessLog logs every request once it is served. Only the path is logged:
+// query strings may carry codes and tokens.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		slog.InfoContext(r.Context(), "Request served",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", r.Pattern,
+			"status", status,
+			"duration", time.Since(start),
+		)
+	})
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import "net/http"
+
+// Deprecated marks a legacy unversioned route: the response advertises its
+// successor and errors keep the original {"error": "..."} shape.
+func Deprecated(successor string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// IsDeprecated reports whether the response is for a deprecated route.
+func IsDeprecated(w http.ResponseWriter) bool {
+	return w.Header().Get("Deprecation") != ""
+}
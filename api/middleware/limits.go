@@ -0,0 +1,12 @@
+package middleware
+
+import "net/http"
+
+// MaxBytes cuts the request body off after n bytes. Reading past the limit
+// fails with an *http.MaxBytesError.
+func MaxBytes(n int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, n)
+		next.ServeHTTP(w, r)
+	})
+}
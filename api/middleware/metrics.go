@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Adedunmol/face-widget/metrics"
+)
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Metrics counts and times requests by route pattern, method and status. It
+// reads the pattern the ServeMux sets on the request, so the middleware
+// between them must pass the request on without copying it.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		// Patterns are "METHOD /path"; unmatched requests share one label
+		// so that random paths cannot blow up the series count.
+		route := "unmatched"
+		if r.Pattern != "" {
+			route = r.Pattern[strings.IndexByte(r.Pattern, ' ')+1:]
+		}
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := []string{route, methodLabel(r.Method), strconv.Itoa(status)}
+		metrics.HTTPRequests.Inc(labels...)
+		metrics.HTTPDuration.Observe(time.Since(start).Seconds(), labels...)
+	})
+}
+
+// methodLabel keeps the standard methods and files any other one, which a
+// client may make up freely, under OTHER.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/Adedunmol/face-widget/api/models"
+)
+
+// Recover turns a panic in a handler into a 500 response instead of a
+// dropped connection, and logs it with its stack.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			// ErrAbortHandler is how a handler deliberately aborts the
+			// response; the server handles it quietly.
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			slog.ErrorContext(r.Context(), "Panic serving request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"panic", err,
+				"stack", string(debug.Stack()),
+			)
+			writeInternalError(w)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeInternalError(w http.ResponseWriter) {
+	var payload interface{} = models.ErrorResponse{
+		Error: models.ErrorBody{
+			Code:      "internal_error",
+			Message:   "Server Error",
+			RequestID: ResponseRequestID(w),
+		},
+	}
+	if IsDeprecated(w) {
+		payload = map[string]string{"error": "Server Error", "code": "internal_error"}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(payload)
+}
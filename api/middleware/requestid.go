@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/Adedunmol/face-widget/logging"
+	"github.com/google/uuid"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// Incoming ids are only reused when they look like an id, so clients cannot
+// inject arbitrary text into logs and responses.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID gives every request an id, taken from the X-Request-ID header
+// when the client sent a valid one, and echoes it on the response. Records
+// logged with the request context carry it.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// GetRequestID returns the id assigned to the request carrying ctx.
+func GetRequestID(ctx context.Context) string {
+	return logging.RequestID(ctx)
+}
+
+// ResponseRequestID returns the id already set on the response headers.
+func ResponseRequestID(w http.ResponseWriter) string {
+	return w.Header().Get(RequestIDHeader)
+}
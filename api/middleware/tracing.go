@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Adedunmol/face-widget/logging"
+	"github.com/Adedunmol/face-widget/tracing"
+)
+
+// Trace records a server span for every request, continuing the trace of
+// the caller's traceparent header. Like Metrics it names the span after the
+// pattern the ServeMux sets, so the middleware it wraps must pass the request
+// on without copying it.
+func Trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.StartServer(r.Context(), tracing.Extract(r.Header), r.Method,
+			tracing.String("http.request.method", r.Method),
+			tracing.String("url.path", r.URL.Path),
+			tracing.String("request.id", logging.RequestID(r.Context())),
+		)
+		defer span.End()
+
+		r = r.WithContext(ctx)
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		if r.Pattern != "" {
+			route := r.Pattern[strings.IndexByte(r.Pattern, ' ')+1:]
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(tracing.String("http.route", route))
+		}
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(tracing.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetError(http.StatusText(status))
+		}
+	})
+}
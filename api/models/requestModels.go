@@ -1,18 +1,94 @@
 package models
 
+// The payloads below arrive either as JSON with Base64 images or as
+// multipart/form-data with one file part per image. Image and FrameImages
+// hold the decoded bytes in both cases.
+
 type RegisterPayload struct {
-	Email        string `json:"email"`
-	FirstName    string `json:"first_name"`
-	LastName     string `json:"last_name"`
-	EncodedImage string `json:"facial_image"` // This will hold the Base64 string
+	Email     string `json:"email" schema:"email"`
+	FirstName string `json:"first_name" schema:"first_name"`
+	LastName  string `json:"last_name" schema:"last_name"`
+	FacePayload
+}
+
+// FacePayload carries the face to enroll, as a single image, a guided
+// multi-angle enrollment or a liveness frame sequence.
+type FacePayload struct {
+	EncodedImage string            `json:"facial_image" schema:"-"` // This will hold the Base64 string
+	Enrollment   *EnrollmentFrames `json:"enrollment,omitempty" schema:"-"`
+	Frames       []string          `json:"frames,omitempty" schema:"-"` // Liveness frame sequence, as sent to /verify_user
+
+	Image       []byte   `json:"-" schema:"-"`
+	FrameImages [][]byte `json:"-" schema:"-"`
+}
+
+// EnrollmentFrames holds the Base64 frames captured for each pose of a
+// guided enrollment.
+type EnrollmentFrames struct {
+	Front       []string `json:"front"`
+	SlightLeft  []string `json:"slight_left"`
+	SlightRight []string `json:"slight_right"`
+
+	FrontImages       [][]byte `json:"-"`
+	SlightLeftImages  [][]byte `json:"-"`
+	SlightRightImages [][]byte `json:"-"`
 }
 
 type VerifyUserPayload struct {
-	Email        string `json:"email"`
-	EncodedImage string `json:"facial_image"`
+	Email        string `json:"email" schema:"email"`
+	EncodedImage string `json:"facial_image" schema:"-"`
+	Nonce        string `json:"nonce,omitempty" schema:"nonce"` // Echoed in the issued token
+
+	Image []byte `json:"-" schema:"-"`
 }
 
 type NewVerifyUserPayload struct {
-	Email  string   `json:"email"`
-	Frames []string `json:"frames"`
+	Email  string   `json:"email" schema:"email"`
+	Frames []string `json:"frames" schema:"-"`
+	Nonce  string   `json:"nonce,omitempty" schema:"nonce"` // Echoed in the issued token
+
+	FrameImages [][]byte `json:"-" schema:"-"`
+}
+
+type DetectPayload struct {
+	EncodedImage string `json:"facial_image"`
+}
+
+type ComparePayload struct {
+	DocumentImage string `json:"document_image"`
+	SelfieImage   string `json:"selfie_image"`
+}
+
+type IdentifyPayload struct {
+	EncodedImage string `json:"facial_image"`
+	Annotate     bool   `json:"annotate"`
+}
+
+type UpdateUserPayload struct {
+	Email     *string `json:"email"`
+	FirstName *string `json:"first_name"`
+	LastName  *string `json:"last_name"`
+}
+
+// ReplaceFacePayload re-enrolls a user. Unless the caller is an admin, the
+// new face must come as liveness frames matching the face currently
+// enrolled.
+type ReplaceFacePayload struct {
+	FacePayload
+}
+
+// CreateAPIKeyPayload describes a new API key. Publishable keys need
+// allowed_origins and may only have the register and verify scopes.
+type CreateAPIKeyPayload struct {
+	Name           string   `json:"name"`
+	Scopes         []string `json:"scopes"`
+	Publishable    bool     `json:"publishable"`
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+// CreateWebhookPayload registers an endpoint. Without events it receives
+// every event.
+type CreateWebhookPayload struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
 }
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/tenants"
+	"github.com/Adedunmol/face-widget/core"
+)
+
+type Box struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type DetectedFace struct {
+	Box       Box          `json:"box"`
+	Landmarks []Point      `json:"landmarks"`
+	Size      int          `json:"size"`
+	Pose      *core.Pose   `json:"pose,omitempty"`
+	Quality   core.Quality `json:"quality"`
+}
+
+type DetectResponse struct {
+	Width  int            `json:"width"`
+	Height int            `json:"height"`
+	Count  int            `json:"count"`
+	Faces  []DetectedFace `json:"faces"`
+}
+
+type VerificationDiagnostics struct {
+	Distance        float64      `json:"distance"`
+	Pose            *core.Pose   `json:"pose,omitempty"`
+	FramePoses      []*core.Pose `json:"frame_poses,omitempty"`
+	RectMotion      *float64     `json:"rect_motion,omitempty"`
+	DescriptorShift *float64     `json:"descriptor_shift,omitempty"`
+}
+
+// VerificationResponse keeps the user fields at the top level so existing
+// clients reading the user keep working.
+type VerificationResponse struct {
+	User
+	Diagnostics VerificationDiagnostics `json:"diagnostics"`
+	Token       string                  `json:"token"` // Signed JWT, verifiable with the keys at /.well-known/jwks.json
+}
+
+type CompareResponse struct {
+	Distance  float64      `json:"distance"`
+	Threshold float64      `json:"threshold"`
+	Match     bool         `json:"match"`
+	Document  DetectedFace `json:"document"`
+	Selfie    DetectedFace `json:"selfie"`
+}
+
+type IdentifiedFace struct {
+	Box      Box      `json:"box"`
+	User     *User    `json:"user"`     // nil when the face is unknown
+	Distance *float64 `json:"distance"` // nil when nobody is enrolled
+	Matched  bool     `json:"matched"`
+}
+
+type IdentifyResponse struct {
+	Faces          []IdentifiedFace `json:"faces"`
+	AnnotatedImage string           `json:"annotated_image,omitempty"` // Base64 JPEG
+}
+
+type FaceSample struct {
+	ID      int     `json:"id"`
+	Pose    string  `json:"pose"`
+	Quality float64 `json:"quality"`
+	// ImageURL reads the image without credentials for a while.
+	ImageURL  string    `json:"image_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserDetails struct {
+	User
+	Samples []FaceSample `json:"samples"`
+}
+
+type APIKey struct {
+	ID             int        `json:"id"`
+	Name           string     `json:"name"`
+	Prefix         string     `json:"prefix"` // First characters of the key, to tell keys apart
+	Publishable    bool       `json:"publishable"`
+	Scopes         []string   `json:"scopes"`
+	AllowedOrigins []string   `json:"allowed_origins"`
+	CreatedAt      time.Time  `json:"created_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreatedAPIKey carries the key in clear, which is only returned once.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type Webhook struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"` // Empty when subscribed to every event
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreatedWebhook carries the signing secret, which is only returned once.
+type CreatedWebhook struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	WebhookID      int        `json:"webhook_id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"` // pending, delivered or failed
+	Attempts       int        `json:"attempts"`
+	LastStatusCode *int       `json:"last_status_code,omitempty"`
+	LastError      *string    `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"` // Only for pending deliveries
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// TenantSettings shows the overrides of a tenant next to the policy they
+// result in.
+type TenantSettings struct {
+	TenantID int              `json:"tenant_id"`
+	Name     string           `json:"name"`
+	Settings tenants.Settings `json:"settings"`
+	Policy   tenants.Policy   `json:"policy"`
+}
+
+type ErrorBody struct {
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
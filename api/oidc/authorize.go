@@ -0,0 +1,267 @@
+package oidc
+
+import (
+	"database/sql"
+	_ "embed"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/config"
+	"github.com/Adedunmol/face-widget/api/db"
+	"github.com/Adedunmol/face-widget/api/tenants"
+	"github.com/Adedunmol/face-widget/api/token"
+)
+
+//go:embed authorize.html
+var authorizePage string
+
+var authorizeTemplate = template.Must(template.New("authorize").Parse(authorizePage))
+
+type authorizeView struct {
+	Error      string
+	ClientName string
+	RequestID  string
+	FrameCount int
+	VerifyURL  string
+	APIKey     string
+	Action     string
+}
+
+func renderPage(w http.ResponseWriter, r *http.Request, status int, view authorizeView) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := authorizeTemplate.Execute(w, view); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to render authorization page", "error", err)
+	}
+}
+
+// redirectWithError sends the authorization error back to the client, as
+// the specification requires once the redirect URI is known to be valid.
+func redirectWithError(w http.ResponseWriter, r *http.Request, redirectURI, state, code, description string) {
+	params := url.Values{"error": {code}, "error_description": {description}}
+	if state != "" {
+		params.Set("state", state)
+	}
+	http.Redirect(w, r, withQuery(redirectURI, params), http.StatusSeeOther)
+}
+
+func withQuery(uri string, params url.Values) string {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	query := parsed.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// Authorize validates an authorization request, records it and renders the
+// capture page that authenticates the user.
+func Authorize(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	ctx := r.Context()
+
+	client, err := loadClient(ctx, params.Get("client_id"))
+	if err == errUnknownClient {
+		renderPage(w, r, http.StatusBadRequest, authorizeView{Error: "Unknown client."})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to look up OIDC client", "error", err)
+		renderPage(w, r, http.StatusInternalServerError, authorizeView{Error: "Something went wrong, please try again."})
+		return
+	}
+
+	redirectURI := params.Get("redirect_uri")
+	if !client.AllowsRedirect(redirectURI) {
+		renderPage(w, r, http.StatusBadRequest, authorizeView{Error: "The redirect URI is not registered for this client."})
+		return
+	}
+
+	state := params.Get("state")
+	if params.Get("response_type") != "code" {
+		redirectWithError(w, r, redirectURI, state, "unsupported_response_type", "only the code response type is supported")
+		return
+	}
+
+	scope, ok := normalizeScope(params.Get("scope"))
+	if !ok {
+		redirectWithError(w, r, redirectURI, state, "invalid_scope", "the openid scope is required")
+		return
+	}
+
+	challenge := params.Get("code_challenge")
+	if challenge != "" && params.Get("code_challenge_method") != "S256" {
+		redirectWithError(w, r, redirectURI, state, "invalid_request", "only the S256 code challenge method is supported")
+		return
+	}
+
+	tenant, err := tenants.Load(ctx, client.TenantID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to load tenant of OIDC client", "error", err)
+		redirectWithError(w, r, redirectURI, state, "server_error", "failed to start the authorization")
+		return
+	}
+
+	requestID, err := randomToken(24)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to create authorization request", "error", err)
+		redirectWithError(w, r, redirectURI, state, "server_error", "failed to start the authorization")
+		return
+	}
+
+	query := `
+		INSERT INTO oidc_authorizations (
+			id,
+			client_id,
+			redirect_uri,
+			scope,
+			state,
+			nonce,
+			code_challenge,
+			expires_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = db.DB.ExecContext(ctx, query,
+		requestID,
+		client.ID,
+		redirectURI,
+		scope,
+		state,
+		params.Get("nonce"),
+		challenge,
+		time.Now().Add(config.Cfg.OIDC.RequestTTL),
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to create authorization request", "error", err)
+		redirectWithError(w, r, redirectURI, state, "server_error", "failed to start the authorization")
+		return
+	}
+
+	// Clients registered before they got their own key fall back to the
+	// deployment-wide one.
+	apiKey := client.WidgetKey
+	if apiKey == "" {
+		apiKey = config.Cfg.OIDC.WidgetKey
+	}
+
+	renderPage(w, r, http.StatusOK, authorizeView{
+		ClientName: client.Name,
+		RequestID:  requestID,
+		FrameCount: tenant.Policy().LivenessFrameCount,
+		VerifyURL:  "/v1/verify_user",
+		APIKey:     apiKey,
+		Action:     AuthorizePath,
+	})
+}
+
+// normalizeScope keeps the supported scopes of a request, which must ask for
+// openid.
+func normalizeScope(raw string) (string, bool) {
+	requested := map[string]bool{}
+	for _, scope := range strings.Fields(raw) {
+		requested[scope] = true
+	}
+	if !requested["openid"] {
+		return "", false
+	}
+
+	var granted []string
+	for _, scope := range supportedScopes {
+		if requested[scope] {
+			granted = append(granted, scope)
+		}
+	}
+	return strings.Join(granted, " "), true
+}
+
+// CompleteAuthorization receives the verification token obtained by the
+// capture page and redirects back to the client with an authorization code.
+func CompleteAuthorization(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	requestID := r.PostFormValue("request_id")
+
+	query := `
+		SELECT
+			a.redirect_uri,
+			a.state,
+			c.tenant_id
+		FROM oidc_authorizations a
+		JOIN oidc_clients c ON c.client_id = a.client_id
+		WHERE a.id = $1 AND a.code_hash IS NULL AND a.expires_at > NOW()`
+	var redirectURI, state string
+	var tenantID int
+	err := db.DB.QueryRowContext(ctx, query, requestID).Scan(&redirectURI, &state, &tenantID)
+	if err == sql.ErrNoRows {
+		renderPage(w, r, http.StatusBadRequest, authorizeView{Error: "This sign-in request has expired, please start again."})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to look up authorization request", "error", err)
+		renderPage(w, r, http.StatusInternalServerError, authorizeView{Error: "Something went wrong, please try again."})
+		return
+	}
+
+	// The token must come from a liveness verification run for this very
+	// request, which the capture page binds by using the request id as nonce,
+	// and of a user of the client's tenant.
+	var claims token.VerificationClaims
+	if err := token.Keys.Verify(r.PostFormValue("verification_token"), &claims); err != nil ||
+		claims.Issuer != config.Cfg.Token.Issuer ||
+		claims.Nonce != requestID ||
+		claims.TenantID != tenantID ||
+		claims.Method != token.MethodLiveness {
+		redirectWithError(w, r, redirectURI, state, "access_denied", "face verification failed")
+		return
+	}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		redirectWithError(w, r, redirectURI, state, "access_denied", "face verification failed")
+		return
+	}
+
+	code, err := randomToken(32)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to create authorization code", "error", err)
+		redirectWithError(w, r, redirectURI, state, "server_error", "failed to issue a code")
+		return
+	}
+
+	query = `
+		UPDATE oidc_authorizations SET
+			user_id = $2,
+			code_hash = $3,
+			auth_time = $4,
+			expires_at = $5
+		WHERE id = $1 AND code_hash IS NULL`
+	result, err := db.DB.ExecContext(ctx, query,
+		requestID,
+		userID,
+		hashSecret(code),
+		time.Unix(claims.IssuedAt, 0),
+		time.Now().Add(config.Cfg.OIDC.CodeTTL),
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to store authorization code", "error", err)
+		redirectWithError(w, r, redirectURI, state, "server_error", "failed to issue a code")
+		return
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		renderPage(w, r, http.StatusBadRequest, authorizeView{Error: "This sign-in request was already completed."})
+		return
+	}
+
+	params := url.Values{"code": {code}}
+	if state != "" {
+		params.Set("state", state)
+	}
+	http.Redirect(w, r, withQuery(redirectURI, params), http.StatusSeeOther)
+}
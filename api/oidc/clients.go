@@ -0,0 +1,133 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/Adedunmol/face-widget/api/apikeys"
+	"github.com/Adedunmol/face-widget/api/db"
+	"github.com/lib/pq"
+)
+
+var errUnknownClient = errors.New("oidc: unknown client")
+
+type Client struct {
+	ID           string
+	TenantID     int
+	Name         string
+	RedirectURIs []string
+	// WidgetKey is the publishable key the sign-in page verifies faces with.
+	WidgetKey  string
+	secretHash string
+}
+
+// AllowsRedirect reports whether uri is one of the registered redirect URIs.
+// Matching is exact, as the specification requires.
+func (c Client) AllowsRedirect(uri string) bool {
+	for _, allowed := range c.RedirectURIs {
+		if allowed == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisterClient stores a new confidential client of a tenant and returns it
+// with its secret. Only a hash of the secret is kept, it cannot be shown
+// again. The client gets a publishable key of the tenant, limited to the
+// issuer's origin, for its sign-in page.
+func RegisterClient(ctx context.Context, tenantID int, name string, redirectURIs []string) (Client, string, error) {
+	if name == "" || len(redirectURIs) == 0 {
+		return Client{}, "", errors.New("oidc: a client needs a name and at least one redirect URI")
+	}
+	for _, uri := range redirectURIs {
+		parsed, err := url.Parse(uri)
+		if err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+			return Client{}, "", fmt.Errorf("oidc: invalid redirect URI %q", uri)
+		}
+	}
+
+	id, err := randomToken(16)
+	if err != nil {
+		return Client{}, "", err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return Client{}, "", err
+	}
+
+	issuerURL, err := url.Parse(issuer())
+	if err != nil || issuerURL.Scheme == "" || issuerURL.Host == "" {
+		return Client{}, "", errors.New("oidc: OIDC_ISSUER must be set to register clients")
+	}
+	origin := issuerURL.Scheme + "://" + issuerURL.Host
+	_, widgetKey, err := apikeys.Create(ctx, tenantID, "OIDC sign-in: "+name, []string{apikeys.ScopeVerify}, true, []string{origin})
+	if err != nil {
+		return Client{}, "", err
+	}
+
+	client := Client{ID: id, TenantID: tenantID, Name: name, RedirectURIs: redirectURIs, WidgetKey: widgetKey}
+	query := `
+		INSERT INTO oidc_clients (
+			client_id,
+			tenant_id,
+			secret_hash,
+			name,
+			redirect_uris,
+			widget_key
+		) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err = db.DB.ExecContext(ctx, query,
+		id,
+		tenantID,
+		hashSecret(secret),
+		name,
+		pq.Array(redirectURIs),
+		widgetKey,
+	)
+	if err != nil {
+		return Client{}, "", err
+	}
+	return client, secret, nil
+}
+
+func loadClient(ctx context.Context, id string) (Client, error) {
+	query := `
+		SELECT
+			client_id,
+			tenant_id,
+			secret_hash,
+			name,
+			redirect_uris,
+			COALESCE(widget_key, '')
+		FROM oidc_clients
+		WHERE client_id = $1`
+	var client Client
+	err := db.DB.QueryRowContext(ctx, query, id).Scan(
+		&client.ID,
+		&client.TenantID,
+		&client.secretHash,
+		&client.Name,
+		pq.Array(&client.RedirectURIs),
+		&client.WidgetKey,
+	)
+	if err == sql.ErrNoRows {
+		return client, errUnknownClient
+	}
+	return client, err
+}
+
+// authenticateClient loads the client and checks its secret.
+func authenticateClient(ctx context.Context, id, secret string) (Client, error) {
+	client, err := loadClient(ctx, id)
+	if err != nil {
+		return client, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(client.secretHash)) != 1 {
+		return client, errUnknownClient
+	}
+	return client, nil
+}
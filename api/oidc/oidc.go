@@ -0,0 +1,107 @@
+// Package oidc lets relying parties sign users in with their face through the
+// OpenID Connect authorization code flow. The authentication step is the
+// liveness verification of /v1/verify_user: the capture page runs it with the
+// authorization request id as nonce and hands the signed verification token
+// back to the authorization endpoint.
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Adedunmol/face-widget/api/config"
+)
+
+const (
+	DiscoveryPath = "/.well-known/openid-configuration"
+	JWKSPath      = "/.well-known/jwks.json"
+	AuthorizePath = "/oidc/authorize"
+	TokenPath     = "/oidc/token"
+	UserInfoPath  = "/oidc/userinfo"
+)
+
+var supportedScopes = []string{"openid", "profile", "email"}
+
+// Register adds the provider endpoints to mux. The JWKS is served by the
+// caller, it is shared with the verification tokens.
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+DiscoveryPath, Discovery)
+	mux.HandleFunc("GET "+AuthorizePath, Authorize)
+	mux.HandleFunc("POST "+AuthorizePath, CompleteAuthorization)
+	mux.HandleFunc("POST "+TokenPath, Token)
+	mux.HandleFunc("GET "+UserInfoPath, UserInfo)
+	mux.HandleFunc("POST "+UserInfoPath, UserInfo)
+}
+
+type discoveryDocument struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+func Discovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	respondWithJSON(w, http.StatusOK, discoveryDocument{
+		Issuer:                            issuer(),
+		AuthorizationEndpoint:             endpoint(AuthorizePath),
+		TokenEndpoint:                     endpoint(TokenPath),
+		UserInfoEndpoint:                  endpoint(UserInfoPath),
+		JWKSURI:                           endpoint(JWKSPath),
+		ScopesSupported:                   supportedScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{"ES256"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "amr",
+			"email", "name", "given_name", "family_name",
+		},
+	})
+}
+
+func issuer() string {
+	return strings.TrimSuffix(config.Cfg.OIDC.Issuer, "/")
+}
+
+func endpoint(path string) string {
+	return issuer() + path
+}
+
+func respondWithJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
+
+// randomToken returns n random bytes, base64url encoded.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSecret hashes client secrets and codes before they are stored. They
+// are random and long, a plain SHA-256 is enough.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,58 @@
+package oidc
+
+import "testing"
+
+func TestVerifyChallenge(t *testing.T) {
+	// The S256 example of RFC 7636, appendix B.
+	const (
+		verifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	)
+
+	tests := []struct {
+		name      string
+		challenge string
+		verifier  string
+		want      bool
+	}{
+		{"matching verifier", challenge, verifier, true},
+		{"other verifier", challenge, verifier + "x", false},
+		{"plain challenge", verifier, verifier, false},
+		{"padded challenge", challenge + "=", verifier, false},
+		{"empty verifier", challenge, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyChallenge(tt.challenge, tt.verifier); got != tt.want {
+				t.Errorf("verifyChallenge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		want   string
+		wantOK bool
+	}{
+		{"openid only", "openid", "openid", true},
+		{"supported order", "email openid profile", "openid profile email", true},
+		{"unsupported dropped", "openid address phone", "openid", true},
+		{"duplicates", "openid email  email", "openid email", true},
+		{"without openid", "profile email", "", false},
+		{"empty", "", "", false},
+		{"case sensitive", "OpenID", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := normalizeScope(tt.raw)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("normalizeScope(%q) = %q, %v, want %q, %v", tt.raw, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
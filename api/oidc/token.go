@@ -0,0 +1,240 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/config"
+	"github.com/Adedunmol/face-widget/api/db"
+	"github.com/Adedunmol/face-widget/api/models"
+	"github.com/Adedunmol/face-widget/api/token"
+)
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	IDToken     string `json:"id_token"`
+	Scope       string `json:"scope"`
+}
+
+type oauthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func respondWithOAuthError(w http.ResponseWriter, status int, code, description string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oidc"`)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, status, oauthError{Error: code, Description: description})
+}
+
+// IDClaims are the claims of an ID token. amr is always face, RFC 8176's
+// method for facial recognition.
+type IDClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  string   `json:"aud"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+	AuthTime  int64    `json:"auth_time"`
+	Nonce     string   `json:"nonce,omitempty"`
+	AMR       []string `json:"amr"`
+	Profile
+}
+
+// Profile holds the user claims granted by the email and profile scopes.
+type Profile struct {
+	Email      string `json:"email,omitempty"`
+	Name       string `json:"name,omitempty"`
+	GivenName  string `json:"given_name,omitempty"`
+	FamilyName string `json:"family_name,omitempty"`
+}
+
+// AccessClaims are the claims of an access token. Its audience is the
+// userinfo endpoint, the only resource it grants access to.
+type AccessClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	Audience  string `json:"aud"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	ClientID  string `json:"client_id"`
+	Scope     string `json:"scope"`
+}
+
+// Token exchanges an authorization code for an ID token and an access token.
+func Token(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	client, err := authenticateClient(ctx, clientID, secret)
+	if err == errUnknownClient {
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to authenticate OIDC client", "error", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		respondWithOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	}
+
+	// Deleting the authorization makes the code single use.
+	query := `
+		DELETE FROM oidc_authorizations
+		WHERE code_hash = $1 AND client_id = $2 AND expires_at > NOW()
+		RETURNING
+			redirect_uri,
+			scope,
+			nonce,
+			code_challenge,
+			user_id,
+			auth_time`
+	var redirectURI, scope, nonce, challenge string
+	var userID int
+	var authTime time.Time
+	err = db.DB.QueryRowContext(ctx, query, hashSecret(r.PostFormValue("code")), client.ID).Scan(
+		&redirectURI,
+		&scope,
+		&nonce,
+		&challenge,
+		&userID,
+		&authTime,
+	)
+	if err == sql.ErrNoRows {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "the code is invalid or expired")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to redeem authorization code", "error", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	if r.PostFormValue("redirect_uri") != redirectURI {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "redirect_uri does not match the authorization request")
+		return
+	}
+	if challenge != "" && !verifyChallenge(challenge, r.PostFormValue("code_verifier")) {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "code_verifier does not match the code challenge")
+		return
+	}
+
+	user, err := loadUser(r, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to look up user", "error", err)
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "the user no longer exists")
+		return
+	}
+
+	now := time.Now()
+	ttl := config.Cfg.OIDC.TokenTTL
+	subject := strconv.Itoa(userID)
+
+	idClaims := IDClaims{
+		Issuer:    issuer(),
+		Subject:   subject,
+		Audience:  client.ID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+		AuthTime:  authTime.Unix(),
+		Nonce:     nonce,
+		AMR:       []string{"face"},
+		Profile:   profileOf(user, scope),
+	}
+
+	idToken, err := token.Keys.Sign(idClaims)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to sign ID token", "error", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	accessToken, err := token.Keys.Sign(AccessClaims{
+		Issuer:    issuer(),
+		Subject:   subject,
+		Audience:  endpoint(UserInfoPath),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+		ClientID:  client.ID,
+		Scope:     scope,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to sign access token", "error", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, tokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ttl.Seconds()),
+		IDToken:     idToken,
+		Scope:       scope,
+	})
+}
+
+// verifyChallenge checks a PKCE code verifier against its S256 challenge.
+func verifyChallenge(challenge, verifier string) bool {
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+func hasScope(scope, want string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
+
+func profileOf(user models.User, scope string) Profile {
+	var profile Profile
+	if hasScope(scope, "email") {
+		profile.Email = user.Email
+	}
+	if hasScope(scope, "profile") {
+		profile.Name = user.FirstName + " " + user.LastName
+		profile.GivenName = user.FirstName
+		profile.FamilyName = user.LastName
+	}
+	return profile
+}
+
+func loadUser(r *http.Request, id int) (models.User, error) {
+	query := `
+		SELECT
+			id,
+			email,
+			first_name,
+			last_name
+		FROM users
+		WHERE id = $1`
+	var user models.User
+	err := db.DB.QueryRowContext(r.Context(), query, id).Scan(
+		&user.ID,
+		&user.Email,
+		&user.FirstName,
+		&user.LastName,
+	)
+	return user, err
+}
@@ -0,0 +1,65 @@
+package oidc
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Adedunmol/face-widget/api/token"
+)
+
+type userInfo struct {
+	Subject string `json:"sub"`
+	Profile
+}
+
+func respondWithInvalidToken(w http.ResponseWriter, description string) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token", error_description="`+description+`"`)
+	respondWithJSON(w, http.StatusUnauthorized, oauthError{Error: "invalid_token", Description: description})
+}
+
+// UserInfo returns the claims of the user an access token was issued for.
+func UserInfo(w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "bearer ") {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="oidc"`)
+		respondWithJSON(w, http.StatusUnauthorized, oauthError{Error: "invalid_request", Description: "bearer token required"})
+		return
+	}
+
+	var claims AccessClaims
+	if err := token.Keys.Verify(strings.TrimSpace(header[7:]), &claims); err != nil {
+		respondWithInvalidToken(w, "the access token is invalid or expired")
+		return
+	}
+	// Verification and ID tokens are signed with the same keys, the audience
+	// tells the access tokens apart.
+	if claims.Issuer != issuer() || claims.Audience != endpoint(UserInfoPath) {
+		respondWithInvalidToken(w, "not an access token of this provider")
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		respondWithInvalidToken(w, "not an access token of this provider")
+		return
+	}
+	user, err := loadUser(r, userID)
+	if err == sql.ErrNoRows {
+		respondWithInvalidToken(w, "the user no longer exists")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to look up user", "error", err)
+		respondWithJSON(w, http.StatusInternalServerError, oauthError{Error: "server_error"})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, userInfo{
+		Subject: claims.Subject,
+		Profile: profileOf(user, claims.Scope),
+	})
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Adedunmol/face-widget/api/config"
+	"github.com/Adedunmol/face-widget/api/health"
+	"github.com/Adedunmol/face-widget/api/models"
+	"github.com/Adedunmol/face-widget/api/oidc"
+	"github.com/Adedunmol/face-widget/api/openapi"
+	"github.com/Adedunmol/face-widget/api/storage"
+	"github.com/Adedunmol/face-widget/api/tenants"
+	"github.com/Adedunmol/face-widget/api/token"
+	"github.com/Adedunmol/face-widget/api/webhooks"
+	"github.com/Adedunmol/face-widget/core"
+)
+
+type specOperation struct {
+	Deprecated  bool `json:"deprecated"`
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema struct {
+				Ref string `json:"$ref"`
+			} `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+type specSchema struct {
+	Properties map[string]json.RawMessage `json:"properties"`
+}
+
+type spec struct {
+	Paths      map[string]map[string]specOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]specSchema `json:"schemas"`
+	} `json:"components"`
+}
+
+// schemaModels maps the component schemas of the spec to the Go types that
+// are encoded or decoded as JSON for them.
+var schemaModels = map[string]interface{}{
+	"RegisterPayload":         models.RegisterPayload{},
+	"EnrollmentFrames":        models.EnrollmentFrames{},
+	"VerifyUserPayload":       models.VerifyUserPayload{},
+	"NewVerifyUserPayload":    models.NewVerifyUserPayload{},
+	"DetectPayload":           models.DetectPayload{},
+	"ComparePayload":          models.ComparePayload{},
+	"IdentifyPayload":         models.IdentifyPayload{},
+	"User":                    models.User{},
+	"Box":                     models.Box{},
+	"Point":                   models.Point{},
+	"Pose":                    core.Pose{},
+	"Quality":                 core.Quality{},
+	"DetectedFace":            models.DetectedFace{},
+	"DetectResponse":          models.DetectResponse{},
+	"VerificationDiagnostics": models.VerificationDiagnostics{},
+	"VerificationResponse":    models.VerificationResponse{},
+	"CompareResponse":         models.CompareResponse{},
+	"IdentifiedFace":          models.IdentifiedFace{},
+	"IdentifyResponse":        models.IdentifyResponse{},
+	"UpdateUserPayload":       models.UpdateUserPayload{},
+	"ReplaceFacePayload":      models.ReplaceFacePayload{},
+	"FaceSample":              models.FaceSample{},
+	"UserDetails":             models.UserDetails{},
+	"CreateAPIKeyPayload":     models.CreateAPIKeyPayload{},
+	"APIKey":                  models.APIKey{},
+	"CreatedAPIKey":           models.CreatedAPIKey{},
+	"Settings":                tenants.Settings{},
+	"Policy":                  tenants.Policy{},
+	"TenantSettings":          models.TenantSettings{},
+	"CreateWebhookPayload":    models.CreateWebhookPayload{},
+	"Webhook":                 models.Webhook{},
+	"CreatedWebhook":          models.CreatedWebhook{},
+	"WebhookDelivery":         models.WebhookDelivery{},
+	"WebhookEvent":            webhooks.Event{},
+	"UserEventData":           webhooks.UserData{},
+	"VerificationEventData":   webhooks.VerificationData{},
+	"ErrorBody":               models.ErrorBody{},
+	"ErrorResponse":           models.ErrorResponse{},
+	"HealthCheck":             health.Check{},
+	"HealthReport":            health.Report{},
+	"JWK":                     token.JWK{},
+	"JWKS":                    token.JWKS{},
+}
+
+// muxRoutes are the operations NewMux serves besides Routes, with their
+// paths as the spec writes them.
+var muxRoutes = []string{
+	"GET " + health.LivePath,
+	"GET " + health.ReadyPath,
+	"GET /metrics",
+	"GET /openapi.json",
+	"GET /docs",
+	"GET " + oidc.JWKSPath,
+	"GET " + oidc.DiscoveryPath,
+	"GET " + oidc.AuthorizePath,
+	"POST " + oidc.AuthorizePath,
+	"POST " + oidc.TokenPath,
+	"GET " + oidc.UserInfoPath,
+	"POST " + oidc.UserInfoPath,
+	"GET " + storage.LocalPath + "{key}",
+}
+
+func loadSpec(t *testing.T) spec {
+	t.Helper()
+
+	var s spec
+	if err := json.Unmarshal(openapi.Spec, &s); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	return s
+}
+
+func TestSpecCoversRoutes(t *testing.T) {
+	s := loadSpec(t)
+
+	registered := map[string]bool{}
+	for _, route := range Routes {
+		method := strings.ToLower(route.Method)
+
+		paths := map[string]bool{Version + route.Path: false}
+		if route.Legacy {
+			paths[route.Path] = true
+		}
+
+		for path, deprecated := range paths {
+			registered[method+" "+path] = true
+
+			op, ok := s.Paths[path][method]
+			if !ok {
+				t.Errorf("route %s %s is missing from openapi.json", route.Method, path)
+				continue
+			}
+			if op.Deprecated != deprecated {
+				t.Errorf("%s %s: deprecated is %v in openapi.json, want %v", route.Method, path, op.Deprecated, deprecated)
+			}
+		}
+	}
+
+	for _, route := range muxRoutes {
+		method, path, _ := strings.Cut(route, " ")
+		method = strings.ToLower(method)
+		registered[method+" "+path] = true
+
+		op, ok := s.Paths[path][method]
+		if !ok {
+			t.Errorf("route %s is missing from openapi.json", route)
+			continue
+		}
+		if op.Deprecated {
+			t.Errorf("%s is deprecated in openapi.json", route)
+		}
+	}
+
+	for path, ops := range s.Paths {
+		for method := range ops {
+			if !registered[method+" "+path] {
+				t.Errorf("openapi.json documents %s %s, which is not a registered route", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+// TestMuxRoutesAreServed keeps muxRoutes from listing what NewMux does not
+// serve, with every optional route turned on.
+func TestMuxRoutesAreServed(t *testing.T) {
+	issuer := config.Cfg.OIDC.Issuer
+	config.Cfg.OIDC.Issuer = "https://id.example.com"
+	t.Cleanup(func() { config.Cfg.OIDC.Issuer = issuer })
+	if err := storage.Init(config.StorageConfig{Backend: "local", LocalDir: t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := NewMux()
+	for _, route := range muxRoutes {
+		method, path, _ := strings.Cut(route, " ")
+		req := httptest.NewRequest(method, strings.ReplaceAll(path, "{key}", "tenant/face.jpg"), nil)
+		if _, pattern := mux.Handler(req); pattern == "" {
+			t.Errorf("%s is not served by NewMux", route)
+		}
+	}
+}
+
+func TestSpecRequestBodiesAreModelled(t *testing.T) {
+	s := loadSpec(t)
+
+	for path, ops := range s.Paths {
+		for method, op := range ops {
+			if op.RequestBody == nil {
+				continue
+			}
+			content, ok := op.RequestBody.Content["application/json"]
+			if !ok {
+				continue
+			}
+
+			name := strings.TrimPrefix(content.Schema.Ref, "#/components/schemas/")
+			if _, ok := schemaModels[name]; !ok {
+				t.Errorf("%s %s: request schema %q has no Go model in schemaModels", strings.ToUpper(method), path, name)
+			}
+		}
+	}
+}
+
+func TestSpecSchemasMatchModels(t *testing.T) {
+	s := loadSpec(t)
+
+	for name, model := range schemaModels {
+		schema, ok := s.Components.Schemas[name]
+		if !ok {
+			t.Errorf("schema %s is missing from openapi.json", name)
+			continue
+		}
+
+		var documented []string
+		for property := range schema.Properties {
+			documented = append(documented, property)
+		}
+		sort.Strings(documented)
+
+		fields := jsonFields(reflect.TypeOf(model))
+		if !reflect.DeepEqual(documented, fields) {
+			t.Errorf("schema %s documents %v, model %T has %v", name, documented, model, fields)
+		}
+	}
+}
+
+// jsonFields lists the JSON names of a struct's encoded fields, flattening
+// embedded structs the way encoding/json does.
+func jsonFields(t reflect.Type) []string {
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			fields = append(fields, jsonFields(field.Type)...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type MemoryStore struct {
+	mu        sync.Mutex
+	failures  map[string][]time.Time
+	lockouts  map[string]Lockout
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		failures: map[string][]time.Time{},
+		lockouts: map[string]Lockout{},
+	}
+}
+
+func (s *MemoryStore) Reserve(_ context.Context, keys []string, lockoutKey string, t time.Time, keep time.Duration, check func([][]time.Time, *Lockout) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := t.Add(-keep)
+	failures := make([][]time.Time, len(keys))
+	for i, key := range keys {
+		failures[i] = after(s.failures[key], cutoff)
+	}
+	lockout := s.lockouts[lockoutKey]
+	if err := check(failures, &lockout); err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		s.failures[key] = insert(failures[i], t)
+	}
+	if lockoutKey != "" {
+		s.lockouts[lockoutKey] = lockout
+	}
+
+	// Keys that stop failing would otherwise stay forever.
+	if t.Sub(s.lastSweep) > keep {
+		for k, times := range s.failures {
+			if times = after(times, cutoff); len(times) == 0 {
+				delete(s.failures, k)
+			} else {
+				s.failures[k] = times
+			}
+		}
+		s.lastSweep = t
+	}
+	return nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, keys []string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		times := s.failures[key]
+		for i, at := range times {
+			if at.Equal(t) {
+				s.failures[key] = append(times[:i:i], times[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// after returns the tail of the sorted times that is after t.
+func after(times []time.Time, t time.Time) []time.Time {
+	for i, at := range times {
+		if at.After(t) {
+			return times[i:]
+		}
+	}
+	return nil
+}
+
+// insert adds t to the sorted times. Reservations made at the same time may
+// take the lock out of order.
+func insert(times []time.Time, t time.Time) []time.Time {
+	i := sort.Search(len(times), func(i int) bool { return times[i].After(t) })
+	return append(times[:i:i], append([]time.Time{t}, times[i:]...)...)
+}
+
+func (s *MemoryStore) Clear(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failures, key)
+	delete(s.lockouts, key)
+	return nil
+}
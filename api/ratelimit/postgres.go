@@ -0,0 +1,166 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/db"
+)
+
+// PostgresStore keeps the failures in the database, so that every instance
+// of the server sees them.
+type PostgresStore struct{}
+
+func (PostgresStore) Reserve(ctx context.Context, keys []string, lockoutKey string, t time.Time, keep time.Duration, check func([][]time.Time, *Lockout) error) error {
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Taking the locks in order keeps concurrent reservations sharing
+	// keys from deadlocking.
+	locked := append([]string(nil), keys...)
+	if lockoutKey != "" {
+		locked = append(locked, lockoutKey)
+	}
+	sort.Strings(locked)
+	for i, key := range locked {
+		if i > 0 && key == locked[i-1] {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('rate_limit:' || $1))`, key); err != nil {
+			return err
+		}
+	}
+
+	cutoff := t.Add(-keep)
+	failures := make([][]time.Time, len(keys))
+	for i, key := range keys {
+		if failures[i], err = failuresSince(ctx, tx, key, cutoff); err != nil {
+			return err
+		}
+	}
+
+	var lockout Lockout
+	if lockoutKey != "" {
+		if lockout, err = loadLockout(ctx, tx, lockoutKey); err != nil {
+			return err
+		}
+	}
+
+	if err := check(failures, &lockout); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		query := `
+			INSERT INTO rate_limit_failures (key, failed_at)
+			VALUES ($1, $2)`
+		if _, err := tx.ExecContext(ctx, query, key, t); err != nil {
+			return err
+		}
+
+		query = `
+			DELETE FROM rate_limit_failures
+			WHERE key = $1 AND failed_at <= $2`
+		if _, err := tx.ExecContext(ctx, query, key, cutoff); err != nil {
+			return err
+		}
+	}
+
+	if lockoutKey != "" {
+		var until sql.NullTime
+		if !lockout.Until.IsZero() {
+			until = sql.NullTime{Time: lockout.Until, Valid: true}
+		}
+		query := `
+			INSERT INTO account_lockouts (key, failures, lockouts, locked_until)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (key) DO UPDATE SET
+				failures = EXCLUDED.failures,
+				lockouts = EXCLUDED.lockouts,
+				locked_until = EXCLUDED.locked_until`
+		if _, err := tx.ExecContext(ctx, query, lockoutKey, lockout.Failures, lockout.Count, until); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func failuresSince(ctx context.Context, tx *sql.Tx, key string, t time.Time) ([]time.Time, error) {
+	query := `
+		SELECT failed_at
+		FROM rate_limit_failures
+		WHERE key = $1 AND failed_at > $2
+		ORDER BY failed_at`
+	rows, err := tx.QueryContext(ctx, query, key, t)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var times []time.Time
+	for rows.Next() {
+		var at time.Time
+		if err := rows.Scan(&at); err != nil {
+			return nil, err
+		}
+		times = append(times, at)
+	}
+	return times, rows.Err()
+}
+
+func loadLockout(ctx context.Context, tx *sql.Tx, key string) (Lockout, error) {
+	query := `
+		SELECT
+			failures,
+			lockouts,
+			locked_until
+		FROM account_lockouts
+		WHERE key = $1`
+	var lockout Lockout
+	var until sql.NullTime
+	err := tx.QueryRowContext(ctx, query, key).Scan(&lockout.Failures, &lockout.Count, &until)
+	if err == sql.ErrNoRows {
+		return Lockout{}, nil
+	}
+	lockout.Until = until.Time
+	return lockout, err
+}
+
+func (PostgresStore) Release(ctx context.Context, keys []string, t time.Time) error {
+	// A single row goes per key: other reservations may share the time.
+	query := `
+		DELETE FROM rate_limit_failures
+		WHERE ctid = (
+			SELECT ctid
+			FROM rate_limit_failures
+			WHERE key = $1 AND failed_at = $2
+			LIMIT 1
+		)`
+	for _, key := range keys {
+		if _, err := db.DB.ExecContext(ctx, query, key, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (PostgresStore) Clear(ctx context.Context, key string) error {
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM rate_limit_failures WHERE key = $1`, key); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM account_lockouts WHERE key = $1`, key); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,187 @@
+// Package ratelimit slows down attempts to guess a face. It counts failed
+// verifications per user, per client IP and per API key within a sliding
+// window, and locks an account for an exponentially growing cooldown after
+// too many consecutive failures. An attempt is counted as failed when it
+// starts and released when it succeeds, so concurrent attempts cannot
+// overrun the limits.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/config"
+)
+
+// Reasons a verification is refused.
+const (
+	ReasonUser   = "user"
+	ReasonIP     = "ip"
+	ReasonAPIKey = "api_key"
+	ReasonLocked = "locked"
+)
+
+// Verifications limits the verification attempts of the server. It is set by
+// Init.
+var Verifications *Limiter
+
+// Attempt identifies who a verification is made by and for. Zero fields are
+// not tracked.
+type Attempt struct {
+	UserID int
+	IP     string
+	APIKey int
+}
+
+// LimitError is returned by Reserve when an attempt is refused.
+type LimitError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	if e.Reason == ReasonLocked {
+		return fmt.Sprintf("ratelimit: account locked, retry after %s", e.RetryAfter)
+	}
+	return fmt.Sprintf("ratelimit: %s limit reached, retry after %s", e.Reason, e.RetryAfter)
+}
+
+type Limiter struct {
+	store   Store
+	limits  config.RateLimitConfig
+	lockout config.LockoutConfig
+}
+
+func New(store Store, limits config.RateLimitConfig, lockout config.LockoutConfig) *Limiter {
+	return &Limiter{store: store, limits: limits, lockout: lockout}
+}
+
+// Init sets Verifications up with the store named in the configuration.
+func Init(limits config.RateLimitConfig, lockout config.LockoutConfig) error {
+	var store Store
+	switch limits.Store {
+	case "memory":
+		store = NewMemoryStore()
+	case "postgres":
+		store = PostgresStore{}
+	default:
+		return fmt.Errorf("unknown rate limit store %q, expected memory or postgres", limits.Store)
+	}
+
+	Verifications = New(store, limits, lockout)
+	return nil
+}
+
+type window struct {
+	reason string
+	key    string
+	max    int
+}
+
+// windows returns the sliding windows an attempt counts against.
+func (l *Limiter) windows(a Attempt) []window {
+	var windows []window
+	if a.UserID != 0 && l.limits.PerUser > 0 {
+		windows = append(windows, window{ReasonUser, userKey(a.UserID), l.limits.PerUser})
+	}
+	if a.IP != "" && l.limits.PerIP > 0 {
+		windows = append(windows, window{ReasonIP, "ip:" + a.IP, l.limits.PerIP})
+	}
+	if a.APIKey != 0 && l.limits.PerAPIKey > 0 {
+		windows = append(windows, window{ReasonAPIKey, fmt.Sprintf("key:%d", a.APIKey), l.limits.PerAPIKey})
+	}
+	return windows
+}
+
+func userKey(id int) string {
+	return fmt.Sprintf("user:%d", id)
+}
+
+// Reservation is an attempt that Reserve let through. It counts as a failed
+// verification until Succeeded releases it.
+type Reservation struct {
+	attempt Attempt
+	keys    []string
+	at      time.Time
+}
+
+// Reserve returns a *LimitError when the account is locked or one of the
+// windows of the attempt is full. Otherwise it records the attempt as a
+// failure before the verification runs, so that concurrent attempts see it,
+// and locks the account once it reaches the threshold of consecutive
+// failures.
+func (l *Limiter) Reserve(ctx context.Context, a Attempt) (*Reservation, error) {
+	// Postgres keeps microseconds, and Release must find the time again.
+	now := time.Now().Truncate(time.Microsecond)
+
+	windows := l.windows(a)
+	keys := make([]string, len(windows))
+	for i, w := range windows {
+		keys[i] = w.key
+	}
+	var lockoutKey string
+	if a.UserID != 0 && l.lockout.Threshold > 0 {
+		lockoutKey = userKey(a.UserID)
+	}
+
+	err := l.store.Reserve(ctx, keys, lockoutKey, now, l.limits.Window, func(failures [][]time.Time, lockout *Lockout) error {
+		if now.Before(lockout.Until) {
+			return &LimitError{Reason: ReasonLocked, RetryAfter: lockout.Until.Sub(now)}
+		}
+
+		for i, w := range windows {
+			if len(failures[i]) >= w.max {
+				// The window has room again once enough of the oldest
+				// failures slid out of it.
+				oldest := failures[i][len(failures[i])-w.max]
+				return &LimitError{Reason: w.reason, RetryAfter: oldest.Add(l.limits.Window).Sub(now)}
+			}
+		}
+
+		if lockoutKey == "" {
+			return nil
+		}
+		lockout.Failures++
+		if lockout.Failures >= l.lockout.Threshold {
+			lockout.Failures = 0
+			lockout.Count++
+			lockout.Until = now.Add(l.cooldown(lockout.Count))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Reservation{attempt: a, keys: keys, at: now}, nil
+}
+
+// cooldown doubles the lockout duration with every lockout in a row.
+func (l *Limiter) cooldown(count int) time.Duration {
+	d := l.lockout.Cooldown
+	for i := 1; i < count && d < l.lockout.MaxCooldown; i++ {
+		d *= 2
+	}
+	if d > l.lockout.MaxCooldown {
+		d = l.lockout.MaxCooldown
+	}
+	return d
+}
+
+// Succeeded releases a reservation after a successful verification and
+// forgets the failures of the user, lifting a lockout the reservation may
+// have started.
+func (l *Limiter) Succeeded(ctx context.Context, r *Reservation) error {
+	if err := l.store.Release(ctx, r.keys, r.at); err != nil {
+		return err
+	}
+	if r.attempt.UserID == 0 {
+		return nil
+	}
+	return l.store.Clear(ctx, userKey(r.attempt.UserID))
+}
+
+// Unlock lifts the lockout of a user and forgets its failures.
+func (l *Limiter) Unlock(ctx context.Context, userID int) error {
+	return l.store.Clear(ctx, userKey(userID))
+}
@@ -0,0 +1,177 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/config"
+)
+
+var testLimits = config.RateLimitConfig{Window: 15 * time.Minute, PerUser: 3, PerIP: 5}
+
+// step reserves an attempt and, when succeed is set, reports it as a
+// successful verification.
+type step struct {
+	attempt Attempt
+	succeed bool
+	reason  string // of the expected LimitError, empty when let through
+}
+
+func TestLimiter(t *testing.T) {
+	user1 := Attempt{UserID: 1, IP: "203.0.113.1"}
+	user2 := Attempt{UserID: 2, IP: "203.0.113.1"}
+	otherIP := Attempt{UserID: 1, IP: "198.51.100.7"}
+
+	tests := []struct {
+		name    string
+		lockout config.LockoutConfig
+		steps   []step
+	}{
+		{
+			name: "user window",
+			steps: []step{
+				{attempt: user1}, {attempt: user1}, {attempt: user1},
+				{attempt: user1, reason: ReasonUser},
+				{attempt: user2},
+			},
+		},
+		{
+			name: "ip window across users",
+			steps: []step{
+				{attempt: user1}, {attempt: user1}, {attempt: user1},
+				{attempt: user2}, {attempt: user2},
+				{attempt: user2, reason: ReasonIP},
+				{attempt: otherIP, reason: ReasonUser},
+			},
+		},
+		{
+			name: "success releases the attempt and clears the user",
+			steps: []step{
+				{attempt: user1}, {attempt: user1},
+				{attempt: user1, succeed: true},
+				{attempt: user1}, {attempt: user1}, {attempt: user1},
+				{attempt: user1, reason: ReasonUser},
+			},
+		},
+		{
+			name:    "lockout",
+			lockout: config.LockoutConfig{Threshold: 2, Cooldown: time.Minute, MaxCooldown: time.Hour},
+			steps: []step{
+				{attempt: user1}, {attempt: user1},
+				{attempt: otherIP, reason: ReasonLocked},
+				{attempt: user2},
+			},
+		},
+		{
+			name:    "success lifts the lockout it started",
+			lockout: config.LockoutConfig{Threshold: 2, Cooldown: time.Minute, MaxCooldown: time.Hour},
+			steps: []step{
+				{attempt: user1},
+				{attempt: user1, succeed: true},
+				{attempt: user1}, {attempt: user1},
+				{attempt: user1, reason: ReasonLocked},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			limiter := New(NewMemoryStore(), testLimits, tt.lockout)
+
+			for i, s := range tt.steps {
+				reservation, err := limiter.Reserve(ctx, s.attempt)
+
+				var limitErr *LimitError
+				switch {
+				case s.reason == "" && err != nil:
+					t.Fatalf("step %d: Reserve() error = %v, want nil", i, err)
+				case s.reason == "":
+				case !errors.As(err, &limitErr):
+					t.Fatalf("step %d: Reserve() error = %v, want a %s LimitError", i, err, s.reason)
+				case limitErr.Reason != s.reason:
+					t.Fatalf("step %d: Reason = %s, want %s", i, limitErr.Reason, s.reason)
+				case limitErr.RetryAfter <= 0:
+					t.Fatalf("step %d: RetryAfter = %s, want positive", i, limitErr.RetryAfter)
+				}
+
+				if s.succeed {
+					if err := limiter.Succeeded(ctx, reservation); err != nil {
+						t.Fatalf("step %d: Succeeded() error = %v", i, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	ctx := context.Background()
+	lockout := config.LockoutConfig{Threshold: 1, Cooldown: time.Minute, MaxCooldown: time.Hour}
+	limiter := New(NewMemoryStore(), testLimits, lockout)
+	attempt := Attempt{UserID: 1}
+
+	if _, err := limiter.Reserve(ctx, attempt); err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+	if _, err := limiter.Reserve(ctx, attempt); err == nil {
+		t.Fatal("Reserve() of a locked account succeeded")
+	}
+	if err := limiter.Unlock(ctx, attempt.UserID); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	if _, err := limiter.Reserve(ctx, attempt); err != nil {
+		t.Errorf("Reserve() after Unlock() error = %v", err)
+	}
+}
+
+// Concurrent attempts must not overrun a window by all checking it before
+// any of them is recorded.
+func TestReserveConcurrently(t *testing.T) {
+	ctx := context.Background()
+	limiter := New(NewMemoryStore(), testLimits, config.LockoutConfig{})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := limiter.Reserve(ctx, Attempt{UserID: 1}); err == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != testLimits.PerUser {
+		t.Errorf("%d attempts allowed, want %d", allowed, testLimits.PerUser)
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	limiter := New(NewMemoryStore(), testLimits, config.LockoutConfig{Cooldown: time.Minute, MaxCooldown: 5 * time.Minute})
+
+	tests := []struct {
+		count int
+		want  time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 5 * time.Minute},
+		{20, 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := limiter.cooldown(tt.count); got != tt.want {
+			t.Errorf("cooldown(%d) = %s, want %s", tt.count, got, tt.want)
+		}
+	}
+}
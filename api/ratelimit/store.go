@@ -0,0 +1,31 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Lockout is the lockout state of an account.
+type Lockout struct {
+	// Failures counts the failures since the last lockout or success.
+	Failures int
+	// Count is the number of lockouts in a row, which sets the cooldown.
+	Count int
+	Until time.Time
+}
+
+// Store keeps the failures and lockouts. The in-memory store only suits a
+// single instance; replicas must share the Postgres store.
+type Store interface {
+	// Reserve runs check on the failures of every key after t - keep,
+	// oldest first, and on the lockout of lockoutKey, while no other
+	// reservation of these keys can run. Unless check returns an error, it
+	// then records a failure of every key at t, may forget the failures
+	// older than t - keep and saves the lockout as check left it. An empty
+	// lockoutKey has no lockout.
+	Reserve(ctx context.Context, keys []string, lockoutKey string, t time.Time, keep time.Duration, check func(failures [][]time.Time, lockout *Lockout) error) error
+	// Release forgets the failure of every key recorded at t.
+	Release(ctx context.Context, keys []string, t time.Time) error
+	// Clear forgets the failures and the lockout of key.
+	Clear(ctx context.Context, key string) error
+}
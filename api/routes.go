@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Adedunmol/face-widget/api/apikeys"
+	"github.com/Adedunmol/face-widget/api/config"
+	"github.com/Adedunmol/face-widget/api/handlers"
+	"github.com/Adedunmol/face-widget/api/health"
+	"github.com/Adedunmol/face-widget/api/middleware"
+	"github.com/Adedunmol/face-widget/api/oidc"
+	"github.com/Adedunmol/face-widget/api/openapi"
+	"github.com/Adedunmol/face-widget/api/storage"
+	"github.com/Adedunmol/face-widget/api/token"
+)
+
+// Version prefixes every current route.
+const Version = "/v1"
+
+type Route struct {
+	Method  string
+	Path    string
+	Handler http.HandlerFunc
+	// Scope is the API key scope the route requires.
+	Scope string
+	// Legacy routes predate versioning and are still served at their
+	// unversioned path as deprecated aliases.
+	Legacy bool
+	// Frames routes accept frame sequences and get the larger body limit.
+	Frames bool
+}
+
+var Routes = []Route{
+	{Method: http.MethodPost, Path: "/register", Handler: handlers.RegisterUser, Scope: apikeys.ScopeRegister, Legacy: true, Frames: true},
+	{Method: http.MethodPost, Path: "/verify", Handler: handlers.VerifyUser, Scope: apikeys.ScopeVerify, Legacy: true},
+	{Method: http.MethodPost, Path: "/verify_user", Handler: handlers.NewVerifyUser, Scope: apikeys.ScopeVerify, Legacy: true, Frames: true},
+	{Method: http.MethodPost, Path: "/detect", Handler: handlers.DetectFaces, Scope: apikeys.ScopeIdentify, Legacy: true},
+	{Method: http.MethodPost, Path: "/compare", Handler: handlers.CompareFaces, Scope: apikeys.ScopeVerify, Legacy: true},
+	{Method: http.MethodPost, Path: "/identify", Handler: handlers.IdentifyFaces, Scope: apikeys.ScopeIdentify, Legacy: true},
+	{Method: http.MethodGet, Path: "/users/{id}", Handler: handlers.GetUser, Scope: apikeys.ScopeAdmin},
+	{Method: http.MethodPatch, Path: "/users/{id}", Handler: handlers.UpdateUser, Scope: apikeys.ScopeAdmin},
+	{Method: http.MethodDelete, Path: "/users/{id}", Handler: handlers.DeleteUser, Scope: apikeys.ScopeAdmin},
+	{Method: http.MethodPut, Path: "/users/{id}/face", Handler: handlers.ReplaceUserFace, Scope: apikeys.ScopeRegister, Frames: true},
+	{Method: http.MethodPost, Path: "/users/{id}/unlock", Handler: handlers.UnlockUser, Scope: apikeys.ScopeAdmin},
+	{Method: http.MethodGet, Path: "/api_keys", Handler: handlers.ListAPIKeys, Scope: apikeys.ScopeAdmin},
+	{Method: http.MethodPost, Path: "/api_keys", Handler: handlers.CreateAPIKey, Scope: apikeys.ScopeAdmin},
+	{Method: http.MethodDelete, Path: "/api_keys/{id}", Handler: handlers.RevokeAPIKey, Scope: apikeys.ScopeAdmin},
+	{Method: http.MethodGet, Path: "/settings", Handler: handlers.GetSettings, Scope: apikeys.ScopeAdmin},
+	{Method: http.MethodPut, Path: "/settings", Handler: handlers.UpdateSettings, Scope: apikeys.ScopeAdmin},
+	{Method: http.MethodGet, Path: "/webhooks", Handler: handlers.ListWebhooks, Scope: apikeys.ScopeAdmin},
+	{Method: http.MethodPost, Path: "/webhooks", Handler: handlers.CreateWebhook, Scope: apikeys.ScopeAdmin},
+	{Method: http.MethodDelete, Path: "/webhooks/{id}", Handler: handlers.DeleteWebhook, Scope: apikeys.ScopeAdmin},
+	{Method: http.MethodGet, Path: "/webhooks/deliveries", Handler: handlers.ListWebhookDeliveries, Scope: apikeys.ScopeAdmin},
+	{Method: http.MethodPost, Path: "/webhooks/deliveries/{id}/replay", Handler: handlers.ReplayWebhookDelivery, Scope: apikeys.ScopeAdmin},
+}
+
+func NewMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	health.Register(mux)
+	mux.HandleFunc("GET /metrics", serveMetrics)
+	mux.HandleFunc("GET /openapi.json", openapi.ServeSpec)
+	mux.HandleFunc("GET /docs", openapi.ServeDocs)
+	mux.HandleFunc("GET "+oidc.JWKSPath, token.ServeJWKS)
+	if config.Cfg.OIDC.Issuer != "" {
+		oidc.Register(mux)
+	}
+	storage.Register(mux)
+
+	for _, route := range Routes {
+		maxBytes := config.Cfg.Server.MaxBodyBytes
+		if route.Frames {
+			maxBytes = config.Cfg.Server.MaxFramesBodyBytes
+		}
+		handler := middleware.MaxBytes(maxBytes, handlers.RequireScope(route.Scope, route.Handler))
+		mux.Handle(route.Method+" "+Version+route.Path, handler)
+		if route.Legacy {
+			mux.Handle(route.Method+" "+route.Path, middleware.Deprecated(Version+route.Path, handler))
+		}
+	}
+
+	return mux
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+)
+
+// Cloudinary keeps the blobs as Cloudinary images. Its references are the
+// secure delivery URLs.
+type Cloudinary struct {
+	cld *cloudinary.Cloudinary
+}
+
+// NewCloudinary reads the credentials from CLOUDINARY_URL.
+func NewCloudinary() (*Cloudinary, error) {
+	cld, err := cloudinary.New()
+	if err != nil {
+		return nil, err
+	}
+	return &Cloudinary{cld: cld}, nil
+}
+
+// Put uploads data as the image whose public id is key without its
+// extension.
+func (c *Cloudinary) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	result, err := c.cld.Upload.Upload(ctx, bytes.NewReader(data), uploader.UploadParams{
+		PublicID: strings.TrimSuffix(key, path.Ext(key)),
+	})
+	if err != nil {
+		return "", err
+	}
+	if result.Error.Message != "" {
+		return "", errors.New(result.Error.Message)
+	}
+	return result.SecureURL, nil
+}
+
+func (c *Cloudinary) Get(ctx context.Context, ref string) ([]byte, error) {
+	if publicIDFromURL(ref) == "" {
+		return nil, ErrForeignReference
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (c *Cloudinary) Delete(ctx context.Context, ref string) error {
+	publicID := publicIDFromURL(ref)
+	if publicID == "" {
+		return ErrForeignReference
+	}
+	result, err := c.cld.Upload.Destroy(ctx, uploader.DestroyParams{PublicID: publicID})
+	if err != nil {
+		return err
+	}
+	if result.Error.Message != "" {
+		return errors.New(result.Error.Message)
+	}
+	return nil
+}
+
+// SignedURL returns the delivery URL with a signature component. Uploaded
+// images are public, so the URL does not expire and ttl is ignored.
+func (c *Cloudinary) SignedURL(ctx context.Context, ref string, ttl time.Duration) (string, error) {
+	publicID := publicIDFromURL(ref)
+	if publicID == "" {
+		return "", ErrForeignReference
+	}
+	image, err := c.cld.Image(publicID)
+	if err != nil {
+		return "", err
+	}
+	image.Config.URL.Secure = true
+	image.Config.URL.SignURL = true
+	return image.String()
+}
+
+func (c *Cloudinary) Ping(ctx context.Context) error {
+	_, err := c.cld.Admin.Ping(ctx)
+	return err
+}
+
+// publicIDFromURL extracts the Cloudinary public id from a delivery URL such
+// as https://res.cloudinary.com/demo/image/upload/v1712/folder/abc.jpg.
+func publicIDFromURL(imageURL string) string {
+	parsed, err := url.Parse(imageURL)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return ""
+	}
+
+	_, rest, ok := strings.Cut(parsed.Path, "/upload/")
+	if !ok {
+		return ""
+	}
+	if version, after, ok := strings.Cut(rest, "/"); ok && len(version) > 1 && version[0] == 'v' {
+		if _, err := strconv.Atoi(version[1:]); err == nil {
+			rest = after
+		}
+	}
+	return strings.TrimSuffix(rest, path.Ext(rest))
+}
@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const localPrefix = "local:"
+
+// Local keeps the blobs as files under a directory. Its references read
+// local:<key>, and its signed URLs point to the route Register adds.
+type Local struct {
+	dir        string
+	baseURL    string
+	signingKey []byte
+}
+
+// NewLocal creates dir if needed. A random signing key is made up when
+// signingKey is empty.
+func NewLocal(dir, baseURL, signingKey string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	key := []byte(signingKey)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	return &Local{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/"), signingKey: key}, nil
+}
+
+func (l *Local) path(ref string) (string, string, error) {
+	key, ok := strings.CutPrefix(ref, localPrefix)
+	if !ok {
+		return "", "", ErrForeignReference
+	}
+	if !validKey(key) {
+		return "", "", ErrInvalidKey
+	}
+	return key, filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes the file through a temporary one, so that a reader never sees
+// it half written.
+func (l *Local) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	ref := localPrefix + key
+	_, name, err := l.path(ref)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+		return "", err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(name), ".put-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), name)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return ref, nil
+}
+
+func (l *Local) Get(ctx context.Context, ref string) ([]byte, error) {
+	_, name, err := l.path(ref)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (l *Local) Delete(ctx context.Context, ref string) error {
+	_, name, err := l.path(ref)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) SignedURL(ctx context.Context, ref string, ttl time.Duration) (string, error) {
+	key, _, err := l.path(ref)
+	if err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	query := url.Values{
+		"expires":   {expires},
+		"signature": {l.sign(key, expires)},
+	}
+	return l.baseURL + "/" + key + "?" + query.Encode(), nil
+}
+
+func (l *Local) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, l.signingKey)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Ping makes sure a file can be written to the directory and deleted.
+func (l *Local) Ping(ctx context.Context) error {
+	file, err := os.CreateTemp(l.dir, ".ping-*")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write([]byte("ping"))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if removeErr := os.Remove(file.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// ServeHTTP serves the blob of a signed URL until it expires.
+func (l *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	expires := r.URL.Query().Get("expires")
+	signature := r.URL.Query().Get("signature")
+
+	deadline, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > deadline || !validKey(key) ||
+		!hmac.Equal([]byte(signature), []byte(l.sign(key, expires))) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	data, err := l.Get(r.Context(), localPrefix+key)
+	if err == ErrNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.FormatInt(max(deadline-time.Now().Unix(), 0), 10))
+	w.Write(data)
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLocalSignedURL(t *testing.T) {
+	ctx := context.Background()
+	l, err := NewLocal(t.TempDir(), "http://localhost:8080"+LocalPath, "signing-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := l.Put(ctx, "faces/1.jpg", []byte("\xff\xd8\xff image"), "image/jpeg")
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET "+LocalPath+"{key...}", l)
+
+	signed := func(ttl time.Duration) url.Values {
+		raw, err := l.SignedURL(ctx, ref, ttl)
+		if err != nil {
+			t.Fatalf("SignedURL() error = %v", err)
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Path != LocalPath+"faces/1.jpg" {
+			t.Fatalf("SignedURL() path = %s", u.Path)
+		}
+		return u.Query()
+	}
+	valid := signed(time.Minute)
+	tampered := []byte(valid.Get("signature"))
+	tampered[0] ^= 1
+
+	tests := []struct {
+		name   string
+		key    string
+		query  url.Values
+		status int
+	}{
+		{"valid", "faces/1.jpg", valid, http.StatusOK},
+		{"expired", "faces/1.jpg", signed(-time.Minute), http.StatusForbidden},
+		{"other key", "faces/2.jpg", valid, http.StatusForbidden},
+		{"extended expiry", "faces/1.jpg", url.Values{
+			"expires":   {strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)},
+			"signature": valid["signature"],
+		}, http.StatusForbidden},
+		{"tampered signature", "faces/1.jpg", url.Values{
+			"expires":   valid["expires"],
+			"signature": {string(tampered)},
+		}, http.StatusForbidden},
+		{"no signature", "faces/1.jpg", url.Values{"expires": valid["expires"]}, http.StatusForbidden},
+		{"no expiry", "faces/1.jpg", url.Values{"signature": valid["signature"]}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, LocalPath+tt.key+"?"+tt.query.Encode(), nil)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.status == http.StatusOK {
+				if got := w.Header().Get("Content-Type"); got != "image/jpeg" {
+					t.Errorf("Content-Type = %q, want image/jpeg", got)
+				}
+				if w.Body.String() != "\xff\xd8\xff image" {
+					t.Errorf("body = %q", w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestLocalKeys(t *testing.T) {
+	ctx := context.Background()
+	l, err := NewLocal(t.TempDir(), "http://localhost:8080"+LocalPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"../outside.jpg", "faces/../../x", "/absolute", "faces//1.jpg", "a b.jpg", ""} {
+		if _, err := l.Put(ctx, key, []byte("x"), ""); err != ErrInvalidKey {
+			t.Errorf("Put(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+	}
+	if _, err := l.Get(ctx, "s3://bucket/faces/1.jpg"); err != ErrForeignReference {
+		t.Errorf("Get() of another backend's reference error = %v, want ErrForeignReference", err)
+	}
+	if _, err := l.Get(ctx, "local:faces/missing.jpg"); err != ErrNotFound {
+		t.Errorf("Get() of a missing blob error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalPing(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	l, err := NewLocal(dir, "http://localhost:8080"+LocalPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Ping(ctx); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Ping() left %d files behind", len(entries))
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Ping(ctx); err == nil {
+		t.Error("Ping() of a removed directory succeeded")
+	}
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/config"
+)
+
+// maxPresignTTL is the longest validity S3 accepts for a presigned URL.
+const maxPresignTTL = 7 * 24 * time.Hour
+
+// S3 keeps the blobs in a bucket of an S3-compatible service, signing its
+// requests with AWS Signature Version 4. Its references read
+// s3://<bucket>/<key>.
+type S3 struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	client    *http.Client
+}
+
+func NewS3(cfg config.StorageConfig) (*S3, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.S3Endpoint, "/"))
+	if err != nil || (endpoint.Scheme != "https" && endpoint.Scheme != "http") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT %q", cfg.S3Endpoint)
+	}
+	if cfg.S3Bucket == "" || cfg.S3AccessKeyID == "" || cfg.S3SecretAccessKey == "" {
+		return nil, errors.New("S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required")
+	}
+	return &S3{
+		endpoint:  endpoint,
+		region:    cfg.S3Region,
+		bucket:    cfg.S3Bucket,
+		accessKey: cfg.S3AccessKeyID,
+		secretKey: cfg.S3SecretAccessKey,
+		pathStyle: cfg.S3PathStyle,
+		client:    &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+func (s *S3) ref(key string) string {
+	return "s3://" + s.bucket + "/" + key
+}
+
+func (s *S3) key(ref string) (string, error) {
+	key, ok := strings.CutPrefix(ref, "s3://"+s.bucket+"/")
+	if !ok {
+		return "", ErrForeignReference
+	}
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	return key, nil
+}
+
+// objectURL addresses key, or the bucket itself when key is empty.
+func (s *S3) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	if s.pathStyle {
+		u.Path += "/" + s.bucket
+		if key != "" {
+			u.Path += "/" + key
+		}
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path += "/" + key
+	}
+	return &u
+}
+
+func (s *S3) do(ctx context.Context, method, key string, body []byte, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	sum := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(sum[:]), time.Now())
+	return s.client.Do(req)
+}
+
+// s3Error reads the error answered by the service.
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	return fmt.Errorf("s3: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	resp, err := s.do(ctx, http.MethodPut, key, data, contentType)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", s3Error(resp)
+	}
+	return s.ref(key), nil
+}
+
+func (s *S3) Get(ctx context.Context, ref string) ([]byte, error) {
+	key, err := s.key(ref)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error(resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (s *S3) Delete(ctx context.Context, ref string) error {
+	key, err := s.key(ref)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+// SignedURL presigns a GET of the object, for at most seven days.
+func (s *S3) SignedURL(ctx context.Context, ref string, ttl time.Duration) (string, error) {
+	key, err := s.key(ref)
+	if err != nil {
+		return "", err
+	}
+	if ttl > maxPresignTTL {
+		ttl = maxPresignTTL
+	}
+	return s.presign(http.MethodGet, s.objectURL(key), ttl, time.Now()), nil
+}
+
+// Ping checks that the bucket exists and the credentials reach it.
+func (s *S3) Ping(ctx context.Context) error {
+	resp, err := s.do(ctx, http.MethodHead, "", nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3: bucket %q: %s", s.bucket, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Package storage keeps the face images. A BlobStore hides where they live:
+// on Cloudinary, in a local directory or in an S3-compatible bucket such as
+// MinIO. Put returns a reference, which is what the database keeps, and the
+// other methods take it back. References of one backend mean nothing to
+// another, so switching backends leaves the images stored so far behind.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/config"
+	"github.com/Adedunmol/face-widget/metrics"
+	"github.com/Adedunmol/face-widget/tracing"
+)
+
+var (
+	ErrNotFound = errors.New("storage: blob not found")
+	// ErrForeignReference is returned for a reference made by another
+	// backend.
+	ErrForeignReference = errors.New("storage: reference belongs to another backend")
+	ErrInvalidKey       = errors.New("storage: invalid key")
+)
+
+type BlobStore interface {
+	// Put stores data under key, a slash separated path, and returns the
+	// reference to keep.
+	Put(ctx context.Context, key string, data []byte, contentType string) (string, error)
+	Get(ctx context.Context, ref string) ([]byte, error)
+	// Delete removes a blob. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, ref string) error
+	// SignedURL returns a URL reading the blob without credentials for ttl.
+	SignedURL(ctx context.Context, ref string, ttl time.Duration) (string, error)
+	// Ping checks that the backend can be reached.
+	Ping(ctx context.Context) error
+}
+
+// Blobs is the store of the face images.
+var Blobs BlobStore
+
+// local is the backend when it is local, for Register.
+var local *Local
+
+// Init sets Blobs up with the backend named in the configuration.
+func Init(cfg config.StorageConfig) error {
+	var store BlobStore
+	switch cfg.Backend {
+	case "cloudinary":
+		cld, err := NewCloudinary()
+		if err != nil {
+			return fmt.Errorf("storage: %w", err)
+		}
+		store = cld
+	case "local":
+		l, err := NewLocal(cfg.LocalDir, cfg.LocalURL, cfg.SigningKey)
+		if err != nil {
+			return fmt.Errorf("storage: %w", err)
+		}
+		local = l
+		store = l
+	case "s3":
+		s3, err := NewS3(cfg)
+		if err != nil {
+			return fmt.Errorf("storage: %w", err)
+		}
+		store = s3
+	default:
+		return fmt.Errorf("unknown storage backend %q, expected cloudinary, local or s3", cfg.Backend)
+	}
+
+	Blobs = traced{BlobStore: store, backend: cfg.Backend}
+	return nil
+}
+
+// LocalPath is the route serving the signed URLs of the local backend.
+const LocalPath = "/storage/"
+
+// Register adds the route serving signed URLs when the backend is local.
+func Register(mux *http.ServeMux) {
+	if local != nil {
+		mux.Handle("GET "+LocalPath+"{key...}", local)
+	}
+}
+
+// validKey accepts slash separated paths of letters, digits, dots, dashes
+// and underscores, without empty or dot-only segments.
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || strings.Trim(segment, ".") == "" {
+			return false
+		}
+		for _, c := range segment {
+			switch {
+			case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '.', c == '-', c == '_':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// traced records a client span for every transfer, and the upload latency.
+type traced struct {
+	BlobStore
+	backend string
+}
+
+func (t traced) Put(ctx context.Context, key string, data []byte, contentType string) (ref string, err error) {
+	ctx, span := tracing.StartClient(ctx, "storage.upload",
+		tracing.String("storage.backend", t.backend),
+		tracing.Int("image.bytes", len(data)),
+	)
+	start := time.Now()
+	defer func() {
+		outcome := "ok"
+		if err != nil {
+			outcome = "error"
+		}
+		metrics.StorageUploadDuration.Observe(time.Since(start).Seconds(), outcome)
+		span.RecordError(err)
+		span.End()
+	}()
+	return t.BlobStore.Put(ctx, key, data, contentType)
+}
+
+func (t traced) Get(ctx context.Context, ref string) (data []byte, err error) {
+	ctx, span := tracing.StartClient(ctx, "storage.download", tracing.String("storage.backend", t.backend))
+	defer func() {
+		span.SetAttributes(tracing.Int("image.bytes", len(data)))
+		span.RecordError(err)
+		span.End()
+	}()
+	return t.BlobStore.Get(ctx, ref)
+}
+
+func (t traced) Delete(ctx context.Context, ref string) (err error) {
+	ctx, span := tracing.StartClient(ctx, "storage.delete", tracing.String("storage.backend", t.backend))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+	return t.BlobStore.Delete(ctx, ref)
+}
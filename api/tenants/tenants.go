@@ -0,0 +1,209 @@
+// Package tenants separates the customers the widget runs for. Users, their
+// face samples and API keys belong to a tenant, and a tenant may override the
+// matching threshold and the liveness policy of the deployment.
+package tenants
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/config"
+	"github.com/Adedunmol/face-widget/api/db"
+)
+
+// DefaultID is the tenant that owned everything before tenants existed. It
+// serves requests made without an API key or with the admin token alone.
+const DefaultID = 1
+
+var (
+	ErrNotFound = errors.New("tenants: tenant not found")
+	ErrInvalid  = errors.New("invalid tenant settings")
+)
+
+// Settings are the overrides of a tenant. A nil field inherits the value of
+// the deployment configuration.
+type Settings struct {
+	MatchThreshold                *float64 `json:"match_threshold,omitempty"`
+	LivenessFrameCount            *int     `json:"liveness_frame_count,omitempty"`
+	LivenessMaxRectMotion         *float64 `json:"liveness_max_rect_motion,omitempty"`
+	LivenessMinDescriptorShift    *float64 `json:"liveness_min_descriptor_shift,omitempty"`
+	RequireLivenessAtRegistration *bool    `json:"require_liveness_at_registration,omitempty"`
+}
+
+func (s Settings) Validate() error {
+	if t := s.MatchThreshold; t != nil && (*t <= 0 || *t > 2) {
+		return fmt.Errorf("%w: match_threshold must be in (0, 2]", ErrInvalid)
+	}
+	if n := s.LivenessFrameCount; n != nil && *n < 2 {
+		return fmt.Errorf("%w: liveness_frame_count must be at least 2", ErrInvalid)
+	}
+	if m := s.LivenessMaxRectMotion; m != nil && *m <= 0 {
+		return fmt.Errorf("%w: liveness_max_rect_motion must be positive", ErrInvalid)
+	}
+	if d := s.LivenessMinDescriptorShift; d != nil && *d < 0 {
+		return fmt.Errorf("%w: liveness_min_descriptor_shift cannot be negative", ErrInvalid)
+	}
+	return nil
+}
+
+// Policy is the effective configuration of a tenant.
+type Policy struct {
+	MatchThreshold                float64 `json:"match_threshold"`
+	LivenessFrameCount            int     `json:"liveness_frame_count"`
+	LivenessMaxRectMotion         float64 `json:"liveness_max_rect_motion"`
+	LivenessMinDescriptorShift    float64 `json:"liveness_min_descriptor_shift"`
+	RequireLivenessAtRegistration bool    `json:"require_liveness_at_registration"`
+}
+
+type Tenant struct {
+	ID        int
+	Name      string
+	Settings  Settings
+	CreatedAt time.Time
+}
+
+// Policy applies the tenant's settings over the deployment configuration.
+func (t *Tenant) Policy() Policy {
+	policy := Policy{
+		MatchThreshold:                config.Cfg.Faces.MatchThreshold,
+		LivenessFrameCount:            config.Cfg.Liveness.FrameCount,
+		LivenessMaxRectMotion:         config.Cfg.Liveness.MaxRectMotion,
+		LivenessMinDescriptorShift:    config.Cfg.Liveness.MinDescriptorShift,
+		RequireLivenessAtRegistration: config.Cfg.Liveness.RequireAtRegistration,
+	}
+
+	s := t.Settings
+	if s.MatchThreshold != nil {
+		policy.MatchThreshold = *s.MatchThreshold
+	}
+	if s.LivenessFrameCount != nil {
+		policy.LivenessFrameCount = *s.LivenessFrameCount
+	}
+	if s.LivenessMaxRectMotion != nil {
+		policy.LivenessMaxRectMotion = *s.LivenessMaxRectMotion
+	}
+	if s.LivenessMinDescriptorShift != nil {
+		policy.LivenessMinDescriptorShift = *s.LivenessMinDescriptorShift
+	}
+	if s.RequireLivenessAtRegistration != nil {
+		policy.RequireLivenessAtRegistration = *s.RequireLivenessAtRegistration
+	}
+	return policy
+}
+
+// StorageFolder is the folder the tenant's images are uploaded to.
+func (t *Tenant) StorageFolder() string {
+	return fmt.Sprintf("tenant_%d", t.ID)
+}
+
+func Load(ctx context.Context, id int) (*Tenant, error) {
+	query := `
+		SELECT
+			id,
+			name,
+			settings,
+			created_at
+		FROM tenants
+		WHERE id = $1`
+	var tenant Tenant
+	var settings []byte
+	err := db.DB.QueryRowContext(ctx, query, id).Scan(
+		&tenant.ID,
+		&tenant.Name,
+		&settings,
+		&tenant.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(settings, &tenant.Settings); err != nil {
+		return nil, fmt.Errorf("tenants: settings of tenant %d: %w", id, err)
+	}
+	return &tenant, nil
+}
+
+func Create(ctx context.Context, name string) (*Tenant, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalid)
+	}
+
+	tenant := Tenant{Name: name}
+	query := `
+		INSERT INTO tenants (name)
+		VALUES ($1)
+		RETURNING id, created_at`
+	if err := db.DB.QueryRowContext(ctx, query, name).Scan(&tenant.ID, &tenant.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func List(ctx context.Context) ([]Tenant, error) {
+	query := `
+		SELECT
+			id,
+			name,
+			settings,
+			created_at
+		FROM tenants
+		ORDER BY id`
+	rows, err := db.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Tenant
+	for rows.Next() {
+		var tenant Tenant
+		var settings []byte
+		if err := rows.Scan(&tenant.ID, &tenant.Name, &settings, &tenant.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(settings, &tenant.Settings); err != nil {
+			return nil, fmt.Errorf("tenants: settings of tenant %d: %w", tenant.ID, err)
+		}
+		list = append(list, tenant)
+	}
+	return list, rows.Err()
+}
+
+// UpdateSettings replaces the overrides of a tenant.
+func UpdateSettings(ctx context.Context, id int, settings Settings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	result, err := db.DB.ExecContext(ctx, `UPDATE tenants SET settings = $2 WHERE id = $1`, id, encoded)
+	if err != nil {
+		return err
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenant)
+}
+
+// FromContext returns the tenant the request was resolved to, or nil.
+func FromContext(ctx context.Context) *Tenant {
+	tenant, _ := ctx.Value(contextKey{}).(*Tenant)
+	return tenant
+}
@@ -0,0 +1,63 @@
+package tenants
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Adedunmol/face-widget/api/config"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		wantErr  bool
+	}{
+		{"empty", Settings{}, false},
+		{"every setting", Settings{
+			MatchThreshold:                ptr(0.12),
+			LivenessFrameCount:            ptr(5),
+			LivenessMaxRectMotion:         ptr(20.0),
+			LivenessMinDescriptorShift:    ptr(0.0),
+			RequireLivenessAtRegistration: ptr(true),
+		}, false},
+		{"threshold at the maximum", Settings{MatchThreshold: ptr(2.0)}, false},
+		{"zero threshold", Settings{MatchThreshold: ptr(0.0)}, true},
+		{"threshold past the maximum", Settings{MatchThreshold: ptr(2.5)}, true},
+		{"single frame", Settings{LivenessFrameCount: ptr(1)}, true},
+		{"zero rect motion", Settings{LivenessMaxRectMotion: ptr(0.0)}, true},
+		{"negative descriptor shift", Settings{LivenessMinDescriptorShift: ptr(-0.1)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.settings.Validate()
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalid) {
+				t.Errorf("Validate() error = %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	config.Cfg.Faces.MatchThreshold = 0.12
+	config.Cfg.Liveness.FrameCount = 5
+	config.Cfg.Liveness.MaxRectMotion = 20
+	config.Cfg.Liveness.MinDescriptorShift = 0.01
+
+	tenant := Tenant{Settings: Settings{MatchThreshold: ptr(0.1), LivenessFrameCount: ptr(3)}}
+	want := Policy{
+		MatchThreshold:             0.1,
+		LivenessFrameCount:         3,
+		LivenessMaxRectMotion:      20,
+		LivenessMinDescriptorShift: 0.01,
+	}
+	if got := tenant.Policy(); got != want {
+		t.Errorf("Policy() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed    = errors.New("token: malformed token")
+	ErrUnknownKey   = errors.New("token: unknown signing key")
+	ErrBadSignature = errors.New("token: invalid signature")
+	ErrExpired      = errors.New("token: token expired")
+)
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+// Sign encodes claims as a JWT signed with the signing key of the set.
+func (s *KeySet) Sign(claims interface{}) (string, error) {
+	head, err := json.Marshal(header{Alg: "ES256", Typ: "JWT", Kid: s.signing})
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(head) + "." + base64.RawURLEncoding.EncodeToString(body)
+	digest := sha256.Sum256([]byte(signed))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.keys[s.signing], digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	// JWS wants the fixed size R || S encoding rather than ASN.1.
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Verify checks the signature and expiry of token and decodes its claims.
+func (s *KeySet) Verify(token string, claims interface{}) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrMalformed
+	}
+
+	rawHead, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return ErrMalformed
+	}
+	var head header
+	if err := json.Unmarshal(rawHead, &head); err != nil || head.Alg != "ES256" {
+		return ErrMalformed
+	}
+	key, ok := s.keys[head.Kid]
+	if !ok {
+		return ErrUnknownKey
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		return ErrBadSignature
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(signature[:32])
+	sig := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(&key.PublicKey, digest[:], r, sig) {
+		return ErrBadSignature
+	}
+
+	body, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ErrMalformed
+	}
+	var registered struct {
+		ExpiresAt int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(body, &registered); err != nil {
+		return ErrMalformed
+	}
+	if registered.ExpiresAt != 0 && time.Now().Unix() >= registered.ExpiresAt {
+		return ErrExpired
+	}
+
+	if err := json.Unmarshal(body, claims); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testClaims struct {
+	Subject   string `json:"sub"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+func newKeySet(t *testing.T, kids ...string) *KeySet {
+	t.Helper()
+	set := &KeySet{signing: kids[0], keys: map[string]*ecdsa.PrivateKey{}}
+	for _, kid := range kids {
+		key, err := GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		set.keys[kid] = key
+	}
+	return set
+}
+
+func sign(t *testing.T, set *KeySet, claims testClaims) string {
+	t.Helper()
+	signed, err := set.Sign(claims)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	return signed
+}
+
+// replacePart swaps one dot-separated part of a token.
+func replacePart(token string, index int, part string) string {
+	parts := strings.Split(token, ".")
+	parts[index] = part
+	return strings.Join(parts, ".")
+}
+
+func TestSignVerify(t *testing.T) {
+	set := newKeySet(t, "current", "retired")
+	valid := sign(t, set, testClaims{Subject: "42", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+
+	retired := *set
+	retired.signing = "retired"
+	other := newKeySet(t, "current")
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"valid", valid, nil},
+		{"no expiry", sign(t, set, testClaims{Subject: "42"}), nil},
+		{"signed by a retired key", sign(t, &retired, testClaims{Subject: "42"}), nil},
+		{"expired", sign(t, set, testClaims{Subject: "42", ExpiresAt: time.Now().Add(-time.Second).Unix()}), ErrExpired},
+		{"unknown key", replacePart(valid, 0, base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","typ":"JWT","kid":"gone"}`))), ErrUnknownKey},
+		{"other key", sign(t, other, testClaims{Subject: "42"}), ErrBadSignature},
+		{"tampered claims", replacePart(valid, 1, base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1"}`))), ErrBadSignature},
+		{"short signature", replacePart(valid, 2, base64.RawURLEncoding.EncodeToString(make([]byte, 32))), ErrBadSignature},
+		{"alg none", replacePart(valid, 0, base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"current"}`))), ErrMalformed},
+		{"two parts", "a.b", ErrMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var claims testClaims
+			err := set.Verify(tt.token, &claims)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && claims.Subject != "42" {
+				t.Errorf("sub = %q, want 42", claims.Subject)
+			}
+		})
+	}
+}
+
+func TestSignatureEncoding(t *testing.T) {
+	set := newKeySet(t, "current")
+	// R and S are padded to 32 bytes each, so every signature is 64 bytes
+	// even when one of them has leading zeros.
+	for i := 0; i < 20; i++ {
+		parts := strings.Split(sign(t, set, testClaims{Subject: "42"}), ".")
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(signature) != 64 {
+			t.Fatalf("signature is %d bytes, want 64", len(signature))
+		}
+	}
+}
+
+func TestEncodeKeyRoundTrip(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := EncodeKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseKey(encoded)
+	if err != nil {
+		t.Fatalf("ParseKey() error = %v", err)
+	}
+	if !parsed.Equal(key) {
+		t.Error("ParseKey() returned a different key")
+	}
+}
@@ -0,0 +1,187 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Adedunmol/face-widget/api/config"
+	"github.com/google/uuid"
+)
+
+// KeySet holds the ES256 keys tokens are signed with. Every key is published
+// in the JWKS so tokens signed by a retired key verify until they expire.
+type KeySet struct {
+	signing string
+	keys    map[string]*ecdsa.PrivateKey
+}
+
+// Keys is the key set of the server, loaded by Init.
+var Keys *KeySet
+
+// Init loads the signing keys from cfg.KeysDir, one PEM file per key named
+// <kid>.pem. To rotate, add the new key, point TOKEN_SIGNING_KEY_ID at it and
+// remove the old file once the tokens it signed have expired. Without a
+// directory an ephemeral key is generated, which does not survive a restart.
+func Init(cfg config.TokenConfig) error {
+	if cfg.KeysDir == "" {
+		key, err := GenerateKey()
+		if err != nil {
+			return err
+		}
+		kid := uuid.NewString()
+		slog.Warn("TOKEN_KEYS_DIR not set, signing tokens with an ephemeral key", "kid", kid)
+		Keys = &KeySet{signing: kid, keys: map[string]*ecdsa.PrivateKey{kid: key}}
+		return nil
+	}
+
+	keys, err := LoadKeySet(cfg.KeysDir, cfg.SigningKeyID)
+	if err != nil {
+		return err
+	}
+	Keys = keys
+	return nil
+}
+
+// LoadKeySet reads every *.pem key of dir. signing names the key that signs,
+// it may be empty when dir holds a single key.
+func LoadKeySet(dir, signing string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	set := &KeySet{signing: signing, keys: map[string]*ecdsa.PrivateKey{}}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("token: %s: %w", path, err)
+		}
+		set.keys[strings.TrimSuffix(filepath.Base(path), ".pem")] = key
+	}
+
+	if len(set.keys) == 0 {
+		return nil, fmt.Errorf("token: no keys in %s", dir)
+	}
+	if set.signing == "" {
+		if len(set.keys) > 1 {
+			return nil, errors.New("token: TOKEN_SIGNING_KEY_ID is required with several keys")
+		}
+		for kid := range set.keys {
+			set.signing = kid
+		}
+	}
+	if _, ok := set.keys[set.signing]; !ok {
+		return nil, fmt.Errorf("token: signing key %q not found in %s", set.signing, dir)
+	}
+	return set, nil
+}
+
+func GenerateKey() (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+// ParseKey parses a PEM encoded P-256 private key in SEC 1 or PKCS #8 form.
+func ParseKey(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var key *ecdsa.PrivateKey
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		parsed, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = parsed
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		ecKey, ok := parsed.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("not an ECDSA key")
+		}
+		key = ecKey
+	default:
+		return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+	}
+
+	if key.Curve != elliptic.P256() {
+		return nil, errors.New("key is not on curve P-256")
+	}
+	return key, nil
+}
+
+// EncodeKey encodes key as a SEC 1 PEM block, the form ParseKey reads back.
+func EncodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set, ordered by kid.
+func (s *KeySet) JWKS() JWKS {
+	kids := make([]string, 0, len(s.keys))
+	for kid := range s.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	jwks := JWKS{Keys: make([]JWK, 0, len(kids))}
+	for _, kid := range kids {
+		public := s.keys[kid].PublicKey
+		jwks.Keys = append(jwks.Keys, JWK{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(public.X.FillBytes(make([]byte, 32))),
+			Y:   base64.RawURLEncoding.EncodeToString(public.Y.FillBytes(make([]byte, 32))),
+			Kid: kid,
+			Use: "sig",
+			Alg: "ES256",
+		})
+	}
+	return jwks
+}
+
+// ServeJWKS publishes the public keys of Keys.
+func ServeJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(Keys.JWKS())
+}
@@ -0,0 +1,59 @@
+package token
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/config"
+	"github.com/google/uuid"
+)
+
+// Verification methods recorded in the method claim.
+const (
+	MethodSingleImage = "single_image"
+	MethodLiveness    = "liveness"
+)
+
+// Scores are the measurements the verification was decided on. Distance
+// is the squared descriptor distance compared with the match threshold.
+type Scores struct {
+	Distance        float64  `json:"distance"`
+	RectMotion      *float64 `json:"rect_motion,omitempty"`
+	DescriptorShift *float64 `json:"descriptor_shift,omitempty"`
+}
+
+// VerificationClaims are the claims of the token issued when a user passes
+// verification. The relying party should check iss, aud, exp and that nonce
+// is the one it handed to the browser.
+type VerificationClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	TenantID  int    `json:"tid"`
+	Audience  string `json:"aud,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	ID        string `json:"jti"`
+	Method    string `json:"method"`
+	Scores    Scores `json:"scores"`
+	Nonce     string `json:"nonce,omitempty"`
+}
+
+// IssueVerification signs a verification token for a user of a tenant with
+// Keys.
+func IssueVerification(tenantID, userID int, method string, scores Scores, nonce string) (string, error) {
+	cfg := config.Cfg.Token
+	now := time.Now()
+
+	return Keys.Sign(VerificationClaims{
+		Issuer:    cfg.Issuer,
+		Subject:   strconv.Itoa(userID),
+		TenantID:  tenantID,
+		Audience:  cfg.Audience,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(cfg.TTL).Unix(),
+		ID:        uuid.NewString(),
+		Method:    method,
+		Scores:    scores,
+		Nonce:     nonce,
+	})
+}
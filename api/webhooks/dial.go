@@ -0,0 +1,85 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// errPrivateAddress refuses deliveries to the server's own network, which a
+// tenant could otherwise probe through the delivery status and error.
+var errPrivateAddress = errors.New("webhooks: refusing to deliver to a non-public address")
+
+// reservedPrefixes are the non-public ranges the netip predicates miss.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+}
+
+// publicAddress reports whether addr may receive webhooks. Loopback,
+// private, link-local, which includes the 169.254.169.254 metadata service
+// of the cloud providers, multicast and reserved addresses may not.
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkHost refuses the hosts of an endpoint URL that are known not to be
+// public without resolving them. Names are checked again when dialing.
+func checkHost(host string) error {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errPrivateAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !publicAddress(addr) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// dialControl runs once the host is resolved, so that a name resolving to a
+// private address, maybe only after validate accepted it, is refused too.
+func dialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !publicAddress(addr) {
+		return fmt.Errorf("%w %s", errPrivateAddress, host)
+	}
+	return nil
+}
+
+// newClient returns the client delivering webhooks. It connects to public
+// addresses only, bypasses any proxy, which would dial for it, and does not
+// follow redirects, which could lead anywhere.
+func newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: dialControl}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: timeout,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,239 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/config"
+	"github.com/Adedunmol/face-widget/api/db"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventIDHeader   = "X-Webhook-ID"
+	EventTypeHeader = "X-Webhook-Event"
+
+	// batchSize is how many due deliveries are claimed at once.
+	batchSize = 20
+	// maxErrorLength bounds the error stored for a failed attempt.
+	maxErrorLength = 500
+)
+
+// wakeup lets Publish and Replay start a delivery round without waiting for
+// the next poll.
+var wakeup = make(chan struct{}, 1)
+
+func wake() {
+	select {
+	case wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Sign returns the X-Webhook-Signature header of body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "t=" + strconv.FormatInt(timestamp, 10) + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type dispatcher struct {
+	cfg    config.WebhookConfig
+	client *http.Client
+}
+
+// Run delivers the due deliveries until ctx is done. Several instances of
+// the server may run it: every delivery is claimed by one of them.
+func Run(ctx context.Context, cfg config.WebhookConfig) {
+	d := &dispatcher{
+		cfg:    cfg,
+		client: newClient(cfg.Timeout),
+	}
+
+	ticker := time.NewTicker(cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		d.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wakeup:
+		}
+	}
+}
+
+type claimed struct {
+	id       int64
+	eventID  string
+	event    string
+	payload  []byte
+	attempts int
+	url      string
+	secret   string
+}
+
+func (d *dispatcher) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		deliveries, err := d.claim(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to claim webhook deliveries", "error", err)
+			return
+		}
+		for _, delivery := range deliveries {
+			d.deliver(ctx, delivery)
+		}
+		if len(deliveries) < batchSize {
+			return
+		}
+	}
+}
+
+// claim takes the due deliveries and pushes their next attempt past the
+// time a delivery may take, so that other instances leave them alone and a
+// delivery interrupted by a crash is retried.
+func (d *dispatcher) claim(ctx context.Context) ([]claimed, error) {
+	query := `
+		UPDATE webhook_deliveries d SET
+			next_attempt_at = NOW() + make_interval(secs => $1)
+		FROM webhook_endpoints e
+		WHERE e.id = d.endpoint_id AND d.id IN (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.event_id, d.event_type, d.payload, d.attempts, e.url, e.secret`
+	lease := (2 * d.cfg.Timeout).Seconds()
+	rows, err := db.DB.QueryContext(ctx, query, lease, batchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []claimed
+	for rows.Next() {
+		var c claimed
+		if err := rows.Scan(&c.id, &c.eventID, &c.event, &c.payload, &c.attempts, &c.url, &c.secret); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, c)
+	}
+	return deliveries, rows.Err()
+}
+
+func (d *dispatcher) deliver(ctx context.Context, c claimed) {
+	statusCode, err := d.post(ctx, c)
+	attempts := c.attempts + 1
+	// A delivery made while shutting down is still recorded, or it would be
+	// sent again.
+	recordCtx := context.WithoutCancel(ctx)
+
+	if err == nil {
+		query := `
+			UPDATE webhook_deliveries SET
+				status = 'delivered',
+				attempts = $2,
+				last_status_code = $3,
+				last_error = NULL,
+				delivered_at = NOW()
+			WHERE id = $1`
+		if _, err := db.DB.ExecContext(recordCtx, query, c.id, attempts, statusCode); err != nil {
+			slog.ErrorContext(ctx, "Failed to record webhook delivery", "delivery_id", c.id, "error", err)
+		}
+		return
+	}
+
+	if ctx.Err() != nil {
+		// Shutting down: the lease expires and the delivery is retried.
+		return
+	}
+	status := StatusPending
+	if attempts >= d.cfg.MaxAttempts {
+		status = StatusFailed
+	}
+	message := err.Error()
+	if len(message) > maxErrorLength {
+		message = message[:maxErrorLength]
+	}
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+	slog.WarnContext(ctx, "Webhook delivery failed",
+		"delivery_id", c.id,
+		"event", c.event,
+		"attempts", attempts,
+		"status", status,
+		"error", err,
+	)
+
+	query := `
+		UPDATE webhook_deliveries SET
+			status = $2,
+			attempts = $3,
+			last_status_code = $4,
+			last_error = $5,
+			next_attempt_at = NOW() + make_interval(secs => $6)
+		WHERE id = $1`
+	_, err = db.DB.ExecContext(recordCtx, query, c.id, status, attempts, code, message, d.backoff(attempts).Seconds())
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to record webhook delivery", "delivery_id", c.id, "error", err)
+	}
+}
+
+// post sends the delivery and returns the status code the endpoint answered
+// with, if any. Only 2xx answers count as delivered.
+func (d *dispatcher) post(ctx context.Context, c claimed) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(c.payload))
+	if err != nil {
+		return 0, err
+	}
+	// Endpoints registered before https was required are not delivered to.
+	if req.URL.Scheme != "https" {
+		return 0, fmt.Errorf("endpoint url is not https")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "face-widget-webhooks")
+	req.Header.Set(EventIDHeader, c.eventID)
+	req.Header.Set(EventTypeHeader, c.event)
+	req.Header.Set(SignatureHeader, Sign(c.secret, time.Now().Unix(), c.payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode/100 != 2 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff is the wait before the next attempt: Backoff after the first
+// failure, twice as long after every further one, up to MaxBackoff.
+func (d *dispatcher) backoff(attempts int) time.Duration {
+	wait := d.cfg.Backoff
+	for i := 1; i < attempts && wait < d.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.cfg.MaxBackoff {
+		wait = d.cfg.MaxBackoff
+	}
+	return wait
+}
@@ -0,0 +1,349 @@
+// Package webhooks notifies the endpoints a tenant registered of what happens
+// to its users. Publish writes every event to an outbox table, in one row per
+// subscribed endpoint, and Run delivers the rows, retrying failed deliveries
+// with exponential backoff.
+//
+// Every delivery is a POST of the JSON event, signed with the endpoint's
+// secret. The X-Webhook-Signature header reads t=<unix time>,v1=<signature>,
+// where the signature is the hex HMAC-SHA256 of "<unix time>.<body>".
+// Receivers should recompute it and reject timestamps too far in the past.
+//
+// Endpoints must be https URLs of public addresses, checked again as every
+// delivery connects, and redirects are not followed: the delivery status
+// would otherwise tell a tenant about the server's own network.
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/db"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	EventUserRegistered        = "user.registered"
+	EventVerificationSucceeded = "verification.succeeded"
+	EventVerificationFailed    = "verification.failed"
+	EventLivenessFailed        = "liveness.failed"
+)
+
+var Events = []string{
+	EventUserRegistered,
+	EventVerificationSucceeded,
+	EventVerificationFailed,
+	EventLivenessFailed,
+}
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+const secretPrefix = "whsec_"
+
+var (
+	ErrNotFound = errors.New("webhooks: not found")
+	ErrPending  = errors.New("webhooks: delivery is still pending")
+	ErrInvalid  = errors.New("invalid webhook settings")
+)
+
+type Endpoint struct {
+	ID        int
+	TenantID  int
+	URL       string
+	Events    []string
+	CreatedAt time.Time
+}
+
+// Event is the body of a delivery.
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	TenantID  int         `json:"tenant_id"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// UserData is the data of user events.
+type UserData struct {
+	UserID int `json:"user_id"`
+}
+
+// VerificationData is the data of verification and liveness events. UserID
+// is left out when the email was unknown. Reason is the error code of a
+// failure.
+type VerificationData struct {
+	UserID          int      `json:"user_id,omitempty"`
+	Method          string   `json:"method"`
+	Reason          string   `json:"reason,omitempty"`
+	Distance        *float64 `json:"distance,omitempty"`
+	RectMotion      *float64 `json:"rect_motion,omitempty"`
+	DescriptorShift *float64 `json:"descriptor_shift,omitempty"`
+}
+
+type Delivery struct {
+	ID             int64
+	EndpointID     int
+	EventID        string
+	EventType      string
+	Status         string
+	Attempts       int
+	LastStatusCode *int
+	LastError      *string
+	NextAttemptAt  time.Time
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+// CreateEndpoint registers an endpoint for the given events, or for every
+// event when there are none. It returns the signing secret, which cannot be
+// read back later.
+func CreateEndpoint(ctx context.Context, tenantID int, endpointURL string, events []string) (Endpoint, string, error) {
+	if err := validate(endpointURL, events); err != nil {
+		return Endpoint{}, "", err
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return Endpoint{}, "", err
+	}
+	secret := secretPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	if events == nil {
+		events = []string{}
+	}
+	endpoint := Endpoint{TenantID: tenantID, URL: endpointURL, Events: events}
+
+	query := `
+		INSERT INTO webhook_endpoints (
+			tenant_id,
+			url,
+			secret,
+			events
+		) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+	err := db.DB.QueryRowContext(ctx, query,
+		endpoint.TenantID,
+		endpoint.URL,
+		secret,
+		pq.Array(endpoint.Events),
+	).Scan(&endpoint.ID, &endpoint.CreatedAt)
+	if err != nil {
+		return Endpoint{}, "", err
+	}
+	return endpoint, secret, nil
+}
+
+func validate(endpointURL string, events []string) error {
+	parsed, err := url.Parse(endpointURL)
+	if err != nil || parsed.Scheme != "https" || parsed.Hostname() == "" {
+		return fmt.Errorf("%w: invalid url %q, expected an https URL", ErrInvalid, endpointURL)
+	}
+	if err := checkHost(parsed.Hostname()); err != nil {
+		return fmt.Errorf("%w: url %q does not point to a public address", ErrInvalid, endpointURL)
+	}
+	for _, event := range events {
+		known := false
+		for _, e := range Events {
+			known = known || e == event
+		}
+		if !known {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalid, event)
+		}
+	}
+	return nil
+}
+
+// ListEndpoints returns the endpoints of a tenant, newest first.
+func ListEndpoints(ctx context.Context, tenantID int) ([]Endpoint, error) {
+	query := `
+		SELECT
+			id,
+			tenant_id,
+			url,
+			events,
+			created_at
+		FROM webhook_endpoints
+		WHERE tenant_id = $1
+		ORDER BY id DESC`
+	rows, err := db.DB.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	endpoints := []Endpoint{}
+	for rows.Next() {
+		var endpoint Endpoint
+		if err := rows.Scan(
+			&endpoint.ID,
+			&endpoint.TenantID,
+			&endpoint.URL,
+			pq.Array(&endpoint.Events),
+			&endpoint.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, rows.Err()
+}
+
+// DeleteEndpoint removes an endpoint of a tenant along with its deliveries.
+func DeleteEndpoint(ctx context.Context, tenantID, id int) error {
+	query := `DELETE FROM webhook_endpoints WHERE id = $1 AND tenant_id = $2`
+	result, err := db.DB.ExecContext(ctx, query, id, tenantID)
+	if err != nil {
+		return err
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Publish records an event for every endpoint of the tenant subscribed to
+// it. The deliveries happen in the background.
+func Publish(ctx context.Context, tenantID int, eventType string, data interface{}) error {
+	event := Event{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		TenantID:  tenantID,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO webhook_deliveries (
+			endpoint_id,
+			event_id,
+			event_type,
+			payload
+		)
+		SELECT id, $2, $3, $4
+		FROM webhook_endpoints
+		WHERE tenant_id = $1 AND (cardinality(events) = 0 OR $3 = ANY(events))`
+	result, err := db.DB.ExecContext(ctx, query, tenantID, event.ID, event.Type, payload)
+	if err != nil {
+		return err
+	}
+	if queued, _ := result.RowsAffected(); queued > 0 {
+		wake()
+	}
+	return nil
+}
+
+// ListDeliveries returns the latest deliveries of a tenant, newest first,
+// optionally only those with the given status.
+func ListDeliveries(ctx context.Context, tenantID int, status string, limit int) ([]Delivery, error) {
+	query := `
+		SELECT
+			d.id,
+			d.endpoint_id,
+			d.event_id,
+			d.event_type,
+			d.status,
+			d.attempts,
+			d.last_status_code,
+			d.last_error,
+			d.next_attempt_at,
+			d.created_at,
+			d.delivered_at
+		FROM webhook_deliveries d
+		JOIN webhook_endpoints e ON e.id = d.endpoint_id
+		WHERE e.tenant_id = $1 AND ($2 = '' OR d.status = $2)
+		ORDER BY d.id DESC
+		LIMIT $3`
+	rows, err := db.DB.QueryContext(ctx, query, tenantID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		var delivery Delivery
+		var statusCode sql.NullInt64
+		var lastError sql.NullString
+		var deliveredAt sql.NullTime
+		if err := rows.Scan(
+			&delivery.ID,
+			&delivery.EndpointID,
+			&delivery.EventID,
+			&delivery.EventType,
+			&delivery.Status,
+			&delivery.Attempts,
+			&statusCode,
+			&lastError,
+			&delivery.NextAttemptAt,
+			&delivery.CreatedAt,
+			&deliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		if statusCode.Valid {
+			code := int(statusCode.Int64)
+			delivery.LastStatusCode = &code
+		}
+		if lastError.Valid {
+			delivery.LastError = &lastError.String
+		}
+		if deliveredAt.Valid {
+			delivery.DeliveredAt = &deliveredAt.Time
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// Replay queues a delivery of a tenant again, with a fresh set of attempts.
+// The event keeps its id, so receivers can tell it is a replay.
+func Replay(ctx context.Context, tenantID int, id int64) error {
+	query := `
+		UPDATE webhook_deliveries d SET
+			status = 'pending',
+			attempts = 0,
+			next_attempt_at = NOW(),
+			delivered_at = NULL
+		FROM webhook_endpoints e
+		WHERE d.id = $1 AND e.id = d.endpoint_id AND e.tenant_id = $2 AND d.status <> 'pending'`
+	result, err := db.DB.ExecContext(ctx, query, id, tenantID)
+	if err != nil {
+		return err
+	}
+	if updated, _ := result.RowsAffected(); updated > 0 {
+		wake()
+		return nil
+	}
+
+	// Nothing was updated: tell a pending delivery from a missing one.
+	query = `
+		SELECT d.status
+		FROM webhook_deliveries d
+		JOIN webhook_endpoints e ON e.id = d.endpoint_id
+		WHERE d.id = $1 AND e.tenant_id = $2`
+	var status string
+	err = db.DB.QueryRowContext(ctx, query, id, tenantID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return ErrPending
+}
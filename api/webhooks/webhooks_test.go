@@ -0,0 +1,116 @@
+package webhooks
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	// Expected values computed with
+	// printf '%s' '<timestamp>.<body>' | openssl dgst -sha256 -hmac <secret>
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      string
+		want      string
+	}{
+		{
+			name:      "event",
+			secret:    "whsec_test",
+			timestamp: 1700000000,
+			body:      `{"event":"verification.succeeded"}`,
+			want:      "t=1700000000,v1=825e2da7089b260c1be63c797dc2a41b29f12c48404478cecf2907ec602e42f2",
+		},
+		{
+			name:      "empty body",
+			secret:    "whsec_test",
+			timestamp: 1700000000,
+			want:      "t=1700000000,v1=5967f3c560522fa40cf2876ebc3c3a08551dd6959aaade3b413460591895bdcc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Errorf("Sign() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// The signature must change with every input, or a delivery could be
+// replayed with another timestamp or body.
+func TestSignDependsOnEveryInput(t *testing.T) {
+	base := Sign("whsec_test", 1700000000, []byte("{}"))
+	for name, other := range map[string]string{
+		"secret":    Sign("whsec_other", 1700000000, []byte("{}")),
+		"timestamp": Sign("whsec_test", 1700000001, []byte("{}")),
+		"body":      Sign("whsec_test", 1700000000, []byte("{ }")),
+	} {
+		if other[len("t=1700000000,"):] == base[len("t=1700000000,"):] {
+			t.Errorf("changing the %s kept the signature", name)
+		}
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := publicAddress(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("publicAddress(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		events  []string
+		wantErr bool
+	}{
+		{"https endpoint", "https://hooks.example.com/face", []string{EventUserRegistered}, false},
+		{"every event", "https://hooks.example.com/face", Events, false},
+		{"http endpoint", "http://hooks.example.com/face", nil, true},
+		{"no host", "https:///face", nil, true},
+		{"localhost", "https://localhost:8443/face", nil, true},
+		{"private address", "https://10.0.0.5/face", nil, true},
+		{"metadata service", "https://169.254.169.254/latest", nil, true},
+		{"unknown event", "https://hooks.example.com/face", []string{"user.deleted"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate(tt.url, tt.events)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalid) {
+				t.Errorf("validate() error = %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
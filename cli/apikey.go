@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Adedunmol/face-widget/api/apikeys"
+	"github.com/Adedunmol/face-widget/api/db"
+	"github.com/Adedunmol/face-widget/api/tenants"
+)
+
+// APIKey manages the API keys. Every subcommand acts on the default tenant
+// unless -tenant is given.
+//
+//	main api-key create -name backend -scopes register,verify
+//	main api-key create -tenant 2 -name widget -scopes verify -publishable -origins https://app.example.com
+//	main api-key list
+//	main api-key revoke -id 3
+func APIKey(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("api-key: expected create, list or revoke")
+	}
+
+	switch args[0] {
+	case "create":
+		return createAPIKey(args[1:])
+	case "list":
+		return listAPIKeys(args[1:])
+	case "revoke":
+		return revokeAPIKey(args[1:])
+	}
+	return fmt.Errorf("api-key: unknown subcommand %q", args[0])
+}
+
+func splitList(raw string) []string {
+	var values []string
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func createAPIKey(args []string) error {
+	flags := flag.NewFlagSet("api-key create", flag.ExitOnError)
+	tenant := flags.Int("tenant", tenants.DefaultID, "id of the tenant the key belongs to")
+	name := flags.String("name", "", "name of the key")
+	scopes := flags.String("scopes", "", "comma separated scopes: "+strings.Join(apikeys.Scopes, ", "))
+	publishable := flags.Bool("publishable", false, "create a publishable key for the browser widget")
+	origins := flags.String("origins", "", "comma separated origins a publishable key may be used from")
+	flags.Parse(args)
+
+	db.RunMigrations()
+
+	key, raw, err := apikeys.Create(context.Background(), *tenant, *name, splitList(*scopes), *publishable, splitList(*origins))
+	if err != nil {
+		return fmt.Errorf("api-key: %w", err)
+	}
+
+	fmt.Printf("id:  %d\nkey: %s\n", key.ID, raw)
+	return nil
+}
+
+func listAPIKeys(args []string) error {
+	flags := flag.NewFlagSet("api-key list", flag.ExitOnError)
+	tenant := flags.Int("tenant", tenants.DefaultID, "id of the tenant")
+	flags.Parse(args)
+
+	db.RunMigrations()
+
+	keys, err := apikeys.List(context.Background(), *tenant)
+	if err != nil {
+		return fmt.Errorf("api-key: %w", err)
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "ID\tNAME\tPREFIX\tSCOPES\tORIGINS\tSTATUS")
+	for _, key := range keys {
+		status := "active"
+		if key.RevokedAt != nil {
+			status = "revoked " + key.RevokedAt.Format("2006-01-02")
+		}
+		fmt.Fprintf(out, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			key.ID, key.Name, key.Prefix, strings.Join(key.Scopes, ","), strings.Join(key.AllowedOrigins, ","), status)
+	}
+	return out.Flush()
+}
+
+func revokeAPIKey(args []string) error {
+	flags := flag.NewFlagSet("api-key revoke", flag.ExitOnError)
+	tenant := flags.Int("tenant", tenants.DefaultID, "id of the tenant the key belongs to")
+	id := flags.Int("id", 0, "id of the key to revoke")
+	flags.Parse(args)
+
+	if *id == 0 {
+		flags.Usage()
+		return fmt.Errorf("api-key: -id is required")
+	}
+
+	db.RunMigrations()
+
+	if err := apikeys.Revoke(context.Background(), *tenant, *id); err != nil {
+		return fmt.Errorf("api-key: %w", err)
+	}
+	fmt.Printf("API key %d revoked\n", *id)
+	return nil
+}
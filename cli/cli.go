@@ -0,0 +1,24 @@
+package cli
+
+import "fmt"
+
+// Run executes the named command-line command.
+func Run(command string, args []string) error {
+	switch command {
+	case "cluster":
+		return Cluster(args)
+	case "token-key":
+		return TokenKey(args)
+	case "oidc-client":
+		return OIDCClient(args)
+	case "api-key":
+		return APIKey(args)
+	case "tenant":
+		return Tenant(args)
+	case "audit":
+		return Audit(args)
+	case "backfill-samples":
+		return BackfillSamples(args)
+	}
+	return fmt.Errorf("unknown command %q", command)
+}
@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Adedunmol/face-widget/api/models"
+	"github.com/Adedunmol/face-widget/core"
+	"github.com/Kagami/go-face"
+)
+
+type clusterFace struct {
+	File string          `json:"file"`
+	Rect image.Rectangle `json:"-"`
+	Box  models.Box      `json:"box"`
+	Crop string          `json:"crop,omitempty"`
+}
+
+type cluster struct {
+	ID    int           `json:"id"`
+	Faces []clusterFace `json:"faces"`
+}
+
+type clusterReport struct {
+	Images   int               `json:"images"`
+	Faces    int               `json:"faces"`
+	Clusters []cluster         `json:"clusters"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// Cluster detects every face on the JPEG images of a directory and groups
+// them by identity.
+//
+//	main cluster -dir ./archive -out report.json -crops ./clusters
+func Cluster(args []string) error {
+	flags := flag.NewFlagSet("cluster", flag.ExitOnError)
+	dir := flags.String("dir", "", "directory of JPEG images to cluster")
+	out := flags.String("out", "clusters.json", "path of the JSON report")
+	crops := flags.String("crops", "", "if set, write face crops into one folder per cluster under this directory")
+	flags.Parse(args)
+
+	if *dir == "" {
+		flags.Usage()
+		return fmt.Errorf("cluster: -dir is required")
+	}
+
+	entries, err := os.ReadDir(*dir)
+	if err != nil {
+		return fmt.Errorf("cluster: %w", err)
+	}
+
+	report := clusterReport{Errors: map[string]string{}}
+	var faces []clusterFace
+	var descriptors []face.Descriptor
+	images := map[string]image.Image{}
+
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".jpg" && ext != ".jpeg") {
+			continue
+		}
+		report.Images++
+
+		path := filepath.Join(*dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			report.Errors[entry.Name()] = err.Error()
+			continue
+		}
+
+		detected, err := core.DetectFaces(data)
+		if err != nil {
+			report.Errors[entry.Name()] = err.Error()
+			continue
+		}
+
+		if *crops != "" && len(detected) > 0 {
+			img, _, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				report.Errors[entry.Name()] = err.Error()
+				continue
+			}
+			images[entry.Name()] = img
+		}
+
+		for _, f := range detected {
+			faces = append(faces, clusterFace{
+				File: entry.Name(),
+				Rect: f.Rectangle,
+				Box: models.Box{
+					X:      f.Rectangle.Min.X,
+					Y:      f.Rectangle.Min.Y,
+					Width:  f.Rectangle.Dx(),
+					Height: f.Rectangle.Dy(),
+				},
+			})
+			descriptors = append(descriptors, f.Descriptor)
+		}
+		log.Printf("%s: %d faces", entry.Name(), len(detected))
+	}
+
+	labels := core.ClusterDescriptors(descriptors)
+	clusters := make([]cluster, 0)
+	for i, label := range labels {
+		for len(clusters) <= label {
+			clusters = append(clusters, cluster{ID: len(clusters)})
+		}
+
+		if *crops != "" {
+			crop, err := writeCrop(*crops, label, i, faces[i], images[faces[i].File])
+			if err != nil {
+				return fmt.Errorf("cluster: %w", err)
+			}
+			faces[i].Crop = crop
+		}
+		clusters[label].Faces = append(clusters[label].Faces, faces[i])
+	}
+
+	// Largest groups first, they are the most interesting duplicates.
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return len(clusters[i].Faces) > len(clusters[j].Faces)
+	})
+
+	report.Faces = len(faces)
+	report.Clusters = clusters
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cluster: %w", err)
+	}
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		return fmt.Errorf("cluster: %w", err)
+	}
+
+	log.Printf("%d faces from %d images grouped into %d clusters, report written to %s",
+		report.Faces, report.Images, len(clusters), *out)
+	return nil
+}
+
+func writeCrop(root string, label, index int, f clusterFace, img image.Image) (string, error) {
+	if img == nil {
+		return "", nil
+	}
+
+	dir := filepath.Join(root, fmt.Sprintf("cluster_%03d", label))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	// Pad the detection box so the crop shows the whole head.
+	pad := f.Rect.Dx() / 4
+	bounds := f.Rect.Inset(-pad).Intersect(img.Bounds())
+	sub, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		return "", nil
+	}
+
+	name := fmt.Sprintf("%s_%d.jpg", strings.TrimSuffix(f.File, filepath.Ext(f.File)), index)
+	path := filepath.Join(dir, name)
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if err := jpeg.Encode(file, sub.SubImage(bounds), &jpeg.Options{Quality: 90}); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/Adedunmol/face-widget/api/db"
+	"github.com/Adedunmol/face-widget/api/oidc"
+	"github.com/Adedunmol/face-widget/api/tenants"
+)
+
+// OIDCClient registers a relying party with the OpenID Connect provider and
+// prints its credentials. The secret is shown only once.
+//
+//	main oidc-client -name "Intranet" -redirect-uri https://intranet.example.com/callback
+//	main oidc-client -tenant 2 -name "Portal" -redirect-uri https://portal.example.com/callback
+func OIDCClient(args []string) error {
+	flags := flag.NewFlagSet("oidc-client", flag.ExitOnError)
+	tenant := flags.Int("tenant", tenants.DefaultID, "id of the tenant whose users sign in")
+	name := flags.String("name", "", "name shown to users on the sign-in page")
+	redirectURIs := flags.String("redirect-uri", "", "comma separated redirect URIs of the client")
+	flags.Parse(args)
+
+	if *name == "" || *redirectURIs == "" {
+		flags.Usage()
+		return fmt.Errorf("oidc-client: -name and -redirect-uri are required")
+	}
+
+	db.RunMigrations()
+
+	client, secret, err := oidc.RegisterClient(context.Background(), *tenant, *name, splitList(*redirectURIs))
+	if err != nil {
+		return fmt.Errorf("oidc-client: %w", err)
+	}
+
+	fmt.Printf("client_id:     %s\nclient_secret: %s\n", client.ID, secret)
+	return nil
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/Adedunmol/face-widget/api/config"
+	"github.com/Adedunmol/face-widget/api/db"
+	"github.com/Adedunmol/face-widget/api/storage"
+	"github.com/Adedunmol/face-widget/core"
+	"github.com/lib/pq"
+)
+
+type legacyUser struct {
+	ID       int
+	TenantID int
+	ImageRef string
+}
+
+// BackfillSamples gives the users enrolled before face samples existed a
+// frontal sample made from their facial_image, without which /identify
+// never finds them. Users with samples are left alone, so it can be run
+// again after a partial failure.
+//
+//	main backfill-samples
+//	main backfill-samples -dry-run
+func BackfillSamples(args []string) error {
+	flags := flag.NewFlagSet("backfill-samples", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "list the users without samples and change nothing")
+	flags.Parse(args)
+
+	db.RunMigrations()
+
+	if err := storage.Init(config.Cfg.Storage); err != nil {
+		return fmt.Errorf("backfill-samples: %w", err)
+	}
+
+	ctx := context.Background()
+	users, err := usersWithoutSamples(ctx)
+	if err != nil {
+		return fmt.Errorf("backfill-samples: %w", err)
+	}
+
+	failed := 0
+	for _, user := range users {
+		if *dryRun {
+			fmt.Printf("user %d (tenant %d): %s\n", user.ID, user.TenantID, user.ImageRef)
+			continue
+		}
+		if err := backfillSample(ctx, user); err != nil {
+			log.Printf("user %d: %v", user.ID, err)
+			failed++
+		}
+	}
+
+	if *dryRun {
+		fmt.Printf("%d users without samples\n", len(users))
+		return nil
+	}
+	log.Printf("%d of %d users without samples backfilled", len(users)-failed, len(users))
+	if failed > 0 {
+		return fmt.Errorf("backfill-samples: %d users failed", failed)
+	}
+	return nil
+}
+
+func usersWithoutSamples(ctx context.Context) ([]legacyUser, error) {
+	query := `
+		SELECT u.id, u.tenant_id, u.facial_image
+		FROM users u
+		WHERE NOT EXISTS (SELECT 1 FROM face_samples s WHERE s.user_id = u.id)
+		ORDER BY u.id`
+	rows, err := db.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []legacyUser
+	for rows.Next() {
+		var user legacyUser
+		if err := rows.Scan(&user.ID, &user.TenantID, &user.ImageRef); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// backfillSample detects the face on the user's base image and records it
+// as the user's only sample, pointing at the same image.
+func backfillSample(ctx context.Context, user legacyUser) error {
+	data, err := storage.Blobs.Get(ctx, user.ImageRef)
+	if err != nil {
+		return fmt.Errorf("downloading base image: %w", err)
+	}
+
+	detected, err := core.CheckFaceData(data)
+	if err != nil {
+		return fmt.Errorf("detecting face: %w", err)
+	}
+	quality, _ := core.AssessQualityJPEG(data, detected.Rectangle)
+
+	// The condition keeps a sample enrolled meanwhile from being doubled.
+	query := `
+		INSERT INTO face_samples (tenant_id, user_id, pose, image_url, descriptor, quality)
+		SELECT $1, $2, $3, $4, $5, $6
+		WHERE NOT EXISTS (SELECT 1 FROM face_samples WHERE user_id = $2)`
+	_, err = db.DB.ExecContext(ctx, query,
+		user.TenantID,
+		user.ID,
+		string(core.PoseFront),
+		user.ImageRef,
+		pq.Float32Array(detected.Descriptor[:]),
+		quality.Score,
+	)
+	return err
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Adedunmol/face-widget/api/db"
+	"github.com/Adedunmol/face-widget/api/tenants"
+)
+
+// Tenant manages the tenants.
+//
+//	main tenant create -name acme
+//	main tenant list
+//	main tenant settings -id 2 -set '{"match_threshold":0.1}'
+func Tenant(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("tenant: expected create, list or settings")
+	}
+
+	switch args[0] {
+	case "create":
+		return createTenant(args[1:])
+	case "list":
+		return listTenants()
+	case "settings":
+		return tenantSettings(args[1:])
+	}
+	return fmt.Errorf("tenant: unknown subcommand %q", args[0])
+}
+
+func createTenant(args []string) error {
+	flags := flag.NewFlagSet("tenant create", flag.ExitOnError)
+	name := flags.String("name", "", "unique name of the tenant")
+	flags.Parse(args)
+
+	db.RunMigrations()
+
+	tenant, err := tenants.Create(context.Background(), *name)
+	if err != nil {
+		return fmt.Errorf("tenant: %w", err)
+	}
+
+	fmt.Printf("id: %d\n", tenant.ID)
+	return nil
+}
+
+func listTenants() error {
+	db.RunMigrations()
+
+	list, err := tenants.List(context.Background())
+	if err != nil {
+		return fmt.Errorf("tenant: %w", err)
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "ID\tNAME\tSETTINGS\tCREATED")
+	for _, tenant := range list {
+		settings, _ := json.Marshal(tenant.Settings)
+		fmt.Fprintf(out, "%d\t%s\t%s\t%s\n", tenant.ID, tenant.Name, settings, tenant.CreatedAt.Format("2006-01-02"))
+	}
+	return out.Flush()
+}
+
+// tenantSettings prints the effective policy of a tenant, after replacing its
+// overrides when -set is given.
+func tenantSettings(args []string) error {
+	flags := flag.NewFlagSet("tenant settings", flag.ExitOnError)
+	id := flags.Int("id", tenants.DefaultID, "id of the tenant")
+	set := flags.String("set", "", "JSON object replacing the overrides of the tenant")
+	flags.Parse(args)
+
+	db.RunMigrations()
+	ctx := context.Background()
+
+	if *set != "" {
+		var settings tenants.Settings
+		if err := json.Unmarshal([]byte(*set), &settings); err != nil {
+			return fmt.Errorf("tenant: invalid -set: %w", err)
+		}
+		if err := tenants.UpdateSettings(ctx, *id, settings); err != nil {
+			return fmt.Errorf("tenant: %w", err)
+		}
+	}
+
+	tenant, err := tenants.Load(ctx, *id)
+	if err != nil {
+		return fmt.Errorf("tenant: %w", err)
+	}
+	policy, _ := json.MarshalIndent(tenant.Policy(), "", "  ")
+	fmt.Println(string(policy))
+	return nil
+}
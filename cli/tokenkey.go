@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Adedunmol/face-widget/api/token"
+)
+
+// TokenKey writes a new ES256 signing key into the keys directory. The key is
+// published right away; set TOKEN_SIGNING_KEY_ID to its kid to sign with it.
+//
+//	main token-key -dir ./keys
+func TokenKey(args []string) error {
+	flags := flag.NewFlagSet("token-key", flag.ExitOnError)
+	dir := flags.String("dir", "", "directory of the token signing keys")
+	kid := flags.String("kid", time.Now().UTC().Format("20060102-150405"), "key id of the new key")
+	flags.Parse(args)
+
+	if *dir == "" {
+		flags.Usage()
+		return fmt.Errorf("token-key: -dir is required")
+	}
+
+	key, err := token.GenerateKey()
+	if err != nil {
+		return fmt.Errorf("token-key: %w", err)
+	}
+	data, err := token.EncodeKey(key)
+	if err != nil {
+		return fmt.Errorf("token-key: %w", err)
+	}
+
+	if err := os.MkdirAll(*dir, 0700); err != nil {
+		return fmt.Errorf("token-key: %w", err)
+	}
+	path := filepath.Join(*dir, *kid+".pem")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("token-key: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("token-key: %w", err)
+	}
+
+	log.Printf("Signing key %s written to %s", *kid, path)
+	return nil
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	knownColor   = color.RGBA{R: 0x22, G: 0xc5, B: 0x5e, A: 0xff}
+	unknownColor = color.RGBA{R: 0xef, G: 0x44, B: 0x44, A: 0xff}
+)
+
+type Label struct {
+	Rect  image.Rectangle
+	Text  string
+	Known bool
+}
+
+// Annotate draws a box and a caption for every label on a copy of img and
+// returns it encoded as JPEG.
+func Annotate(img image.Image, labels []Label) ([]byte, error) {
+	canvas := image.NewRGBA(img.Bounds())
+	draw.Draw(canvas, canvas.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	for _, label := range labels {
+		c := unknownColor
+		if label.Known {
+			c = knownColor
+		}
+		drawBox(canvas, label.Rect, c, 2)
+		drawCaption(canvas, label.Rect, label.Text, c)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, canvas, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func drawBox(canvas *image.RGBA, r image.Rectangle, c color.Color, thickness int) {
+	src := image.NewUniform(c)
+	edges := []image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+thickness),
+		image.Rect(r.Min.X, r.Max.Y-thickness, r.Max.X, r.Max.Y),
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+thickness, r.Max.Y),
+		image.Rect(r.Max.X-thickness, r.Min.Y, r.Max.X, r.Max.Y),
+	}
+	for _, edge := range edges {
+		draw.Draw(canvas, edge.Intersect(canvas.Bounds()), src, image.Point{}, draw.Src)
+	}
+}
+
+func drawCaption(canvas *image.RGBA, r image.Rectangle, text string, background color.Color) {
+	face := basicfont.Face7x13
+	drawer := &font.Drawer{
+		Dst:  canvas,
+		Src:  image.NewUniform(color.White),
+		Face: face,
+	}
+
+	width := drawer.MeasureString(text).Ceil() + 4
+	height := face.Metrics().Height.Ceil() + 4
+
+	// Put the caption above the box, or inside it when the box touches the
+	// top of the image.
+	top := r.Min.Y - height
+	if top < canvas.Bounds().Min.Y {
+		top = r.Min.Y
+	}
+	captionRect := image.Rect(r.Min.X, top, r.Min.X+width, top+height)
+	draw.Draw(canvas, captionRect.Intersect(canvas.Bounds()), image.NewUniform(background), image.Point{}, draw.Src)
+
+	drawer.Dot = fixed.P(captionRect.Min.X+2, captionRect.Min.Y+2+face.Metrics().Ascent.Ceil())
+	drawer.DrawString(text)
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"math/rand"
+
+	"github.com/Kagami/go-face"
+)
+
+const clusterIterations = 100
+
+// ClusterDescriptors groups descriptors by identity with the Chinese
+// whispers algorithm: descriptors within Threshold of each other, by
+// MatchDistance, are linked, every node starts in its own cluster and
+// repeatedly adopts the label most common among its neighbours. It returns
+// one cluster id per descriptor, numbered from 0 in order of first
+// appearance.
+func ClusterDescriptors(descriptors []face.Descriptor) []int {
+	n := len(descriptors)
+	neighbours := make([][]int, n)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if MatchDistance(descriptors[i], descriptors[j]) <= Threshold {
+				neighbours[i] = append(neighbours[i], j)
+				neighbours[j] = append(neighbours[j], i)
+			}
+		}
+	}
+
+	labels := make([]int, n)
+	for i := range labels {
+		labels[i] = i
+	}
+
+	// A fixed seed keeps the report stable between runs on the same batch.
+	rnd := rand.New(rand.NewSource(1))
+	for iter := 0; iter < clusterIterations; iter++ {
+		changed := false
+		for _, i := range rnd.Perm(n) {
+			if len(neighbours[i]) == 0 {
+				continue
+			}
+
+			counts := make(map[int]int)
+			for _, j := range neighbours[i] {
+				counts[labels[j]]++
+			}
+			best, bestCount := labels[i], 0
+			for label, count := range counts {
+				if count > bestCount || (count == bestCount && label < best) {
+					best, bestCount = label, count
+				}
+			}
+			if best != labels[i] {
+				labels[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	renumbered := make(map[int]int)
+	for i, label := range labels {
+		id, ok := renumbered[label]
+		if !ok {
+			id = len(renumbered)
+			renumbered[label] = id
+		}
+		labels[i] = id
+	}
+	return labels
+}
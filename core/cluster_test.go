@@ -0,0 +1,42 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Kagami/go-face"
+)
+
+func descriptorsAt(offsets ...float32) []face.Descriptor {
+	descriptors := make([]face.Descriptor, len(offsets))
+	for i, offset := range offsets {
+		descriptors[i] = faceAt(offset).Descriptor
+	}
+	return descriptors
+}
+
+func TestClusterDescriptors(t *testing.T) {
+	tests := []struct {
+		name        string
+		descriptors []face.Descriptor
+		want        []int
+	}{
+		{"empty", nil, []int{}},
+		{"single", descriptorsAt(0), []int{0}},
+		{"two people", descriptorsAt(0, 2, 0.1, 2.1, 0.2), []int{0, 1, 0, 1, 0}},
+		{"all apart", descriptorsAt(0, 1, 2), []int{0, 1, 2}},
+		// 0.3 apart is 0.09 squared, within Threshold: the samples link
+		// into a chain although the ends are 0.6 apart.
+		{"chain", descriptorsAt(0, 0.3, 0.6), []int{0, 0, 0}},
+		{"past the threshold", descriptorsAt(0, 0.35), []int{0, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClusterDescriptors(tt.descriptors)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ClusterDescriptors() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,26 +1,30 @@
 package core
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"github.com/Adedunmol/face-widget/logging"
+	"github.com/Adedunmol/face-widget/metrics"
+	"github.com/Adedunmol/face-widget/tracing"
 	"github.com/Kagami/go-face"
 	"image"
 	_ "image/jpeg"
-	"log"
+	"log/slog"
 	"math"
-	"os"
 	"path/filepath"
 	"time"
 )
 
 const (
-	ModelDir  = "models"
-	ImageDir  = "images"
+	ModelDir = "models"
+	// Threshold is the default match distance, see MatchDistance, within
+	// which two descriptors belong to the same person.
 	Threshold = 0.12
 )
 
 var (
-	ErrFileNotExist  = errors.New("file does not exist")
 	ErrNoMatch       = errors.New("faces do not match")
 	ErrInvalidFormat = errors.New("invalid image format")
 	ErrDecodingImage = errors.New("error decoding image")
@@ -29,93 +33,115 @@ var (
 )
 
 func Init() *face.Recognizer {
-	log.Println("initializing face recognizer")
+	slog.Info("Initializing face recognizer")
 	var err error
 	modelsPath := filepath.Join(".", ModelDir)
 	Rec, err = face.NewRecognizer(modelsPath)
 
 	if err != nil {
-		log.Fatalf("error creating NewRecognizer: %v", err)
+		logging.Fatal("Failed to create face recognizer", "error", err)
 	}
-	log.Println("done initializing face recognizer")
+	slog.Info("Face recognizer ready")
 
 	return Rec
 }
 
-func CompareImages(knownImage, candidateImage string) error {
-	log.Println("comparing images")
-
-	knownImagePath := filepath.Join(".", ImageDir, knownImage)
-	candidateImagePath := filepath.Join(".", ImageDir, candidateImage)
-
-	log.Println("known image path: ", knownImagePath)
-	log.Println("candidate image path: ", candidateImagePath)
-
-	if _, err := os.Stat(candidateImagePath); os.IsNotExist(err) {
-		log.Println("candidate image path not exist")
+// Comparison describes the outcome of matching a candidate image against a
+// known image. Distance is the MatchDistance the match was decided on.
+type Comparison struct {
+	Distance  float64
+	Match     bool
+	Known     *face.Face
+	Candidate *face.Face
+}
 
-		return ErrFileNotExist
+// CompareFaces matches two already detected faces: they match when their
+// match distance is within threshold. It returns ErrNoMatch alongside the
+// comparison when the faces belong to different people.
+func CompareFaces(known, candidate *face.Face, threshold float64) (*Comparison, error) {
+	distance := MatchDistance(known.Descriptor, candidate.Descriptor)
+	comparison := &Comparison{
+		Distance:  distance,
+		Match:     distance <= threshold,
+		Known:     known,
+		Candidate: candidate,
 	}
 
-	if _, err := os.Stat(knownImagePath); os.IsNotExist(err) {
-		log.Println("known image path not exist")
-		return ErrFileNotExist
+	if !comparison.Match {
+		return comparison, ErrNoMatch
 	}
+	return comparison, nil
+}
+
+// CompareImages detects the face on two JPEG images and compares them with
+// CompareFaces.
+func CompareImages(ctx context.Context, knownImage, candidateImage []byte, threshold float64) (_ *Comparison, err error) {
+	ctx, span := tracing.Start(ctx, "compare_images")
+	defer func() {
+		if err != ErrNoMatch {
+			span.RecordError(err)
+		}
+		span.End()
+	}()
 
-	if err := ValidateImage(knownImagePath); err != nil {
-		return err
+	if err := ValidateImage(knownImage); err != nil {
+		return nil, err
 	}
 
-	if err := ValidateImage(candidateImagePath); err != nil {
-		return err
+	if err := ValidateImage(candidateImage); err != nil {
+		return nil, err
 	}
 
 	currentTime := time.Now()
 
-	//defer rec.Close()
-
-	face1, err := CheckFace(knownImagePath)
+	face1, err := checkFaceTraced(ctx, knownImage, "known")
 	if err != nil {
-		log.Println(err.Error())
-		return err
+		return nil, err
 	}
 
-	// Add them to recognizer
-	Rec.SetSamples([]face.Descriptor{
-		face1.Descriptor,
-	}, []int32{0})
-
-	// test with an unknown face
-	testFace, err := CheckFace(candidateImagePath)
+	testFace, err := checkFaceTraced(ctx, candidateImage, "candidate")
 	if err != nil {
-		log.Println(err.Error())
-		return err
+		return nil, err
 	}
-	match := Rec.ClassifyThreshold(testFace.Descriptor, float32(Threshold))
 
-	log.Println("time to classify: ", time.Since(currentTime).Seconds())
-	fmt.Println("euclidean distance: ", face.SquaredEuclideanDistance(face1.Descriptor, testFace.Descriptor))
+	// The recognizer is shared between requests, so the faces are compared
+	// here rather than through its samples.
+	comparison, _ := CompareFaces(face1, testFace, threshold)
+
+	elapsed := time.Since(currentTime)
+	metrics.ComparisonDuration.Observe(elapsed.Seconds())
 
-	if match < 0 {
-		fmt.Println("ClassifyThreshold result: Unknown face")
-		return ErrNoMatch
+	span.SetAttributes(
+		tracing.Float64("face.distance", comparison.Distance),
+		tracing.Bool("face.match", comparison.Match),
+	)
+	slog.DebugContext(ctx, "Compared images",
+		"distance", comparison.Distance,
+		"match", comparison.Match,
+		"duration", elapsed,
+	)
+
+	if !comparison.Match {
+		return comparison, ErrNoMatch
 	}
-	fmt.Println("ClassifyThreshold result: Person index", match)
-	fmt.Println("euclidean distance: ", face.SquaredEuclideanDistance(face1.Descriptor, testFace.Descriptor))
-	return nil
+	return comparison, nil
 }
 
-func ValidateImage(imagePath string) error {
+// checkFaceTraced runs CheckFaceData within a span named after the role of
+// the image in the comparison.
+func checkFaceTraced(ctx context.Context, imageData []byte, role string) (*face.Face, error) {
+	_, span := tracing.Start(ctx, "detect_face", tracing.String("image.role", role))
+	defer span.End()
 
-	file, err := os.Open(imagePath)
-	if err != nil {
-		return fmt.Errorf("error opening file: %v", err)
-	}
-	defer file.Close()
+	detected, err := CheckFaceData(imageData)
+	span.RecordError(err)
+	return detected, err
+}
 
-	_, format, err := image.DecodeConfig(file)
+// ValidateImage makes sure the image is a JPEG the decoder reads.
+func ValidateImage(imageData []byte) error {
+	_, format, err := image.DecodeConfig(bytes.NewReader(imageData))
 	if err != nil {
-		log.Println(err)
 		return ErrDecodingImage
 	}
 
@@ -126,18 +152,26 @@ func ValidateImage(imagePath string) error {
 	return ErrInvalidFormat
 }
 
-func CheckFace(imagePath string) (*face.Face, error) {
-	face1, err := Rec.RecognizeSingleFile(imagePath)
-	if err != nil {
-		log.Println(err.Error())
-		return nil, fmt.Errorf("error recognizing file: %v", err)
-	}
+// recognize runs a call to the recognizer, which handles one image at a
+// time, and records how long it took and how many calls are queued.
+func recognize(call func() ([]face.Face, error)) ([]face.Face, error) {
+	metrics.RecognizerQueueDepth.Inc()
+	defer metrics.RecognizerQueueDepth.Dec()
 
-	if face1 == nil {
-		return nil, ErrNoFaceFound
+	start := time.Now()
+	faces, err := call()
+	metrics.DetectionDuration.Observe(time.Since(start).Seconds())
+	return faces, err
+}
+
+// DetectFaces returns every face found on a JPEG image.
+func DetectFaces(imageData []byte) ([]face.Face, error) {
+	faces, err := recognize(func() ([]face.Face, error) { return Rec.Recognize(imageData) })
+	if err != nil {
+		return nil, fmt.Errorf("error recognizing image: %v", err)
 	}
 
-	return face1, nil
+	return faces, nil
 }
 
 type FrameData struct {
@@ -145,15 +179,11 @@ type FrameData struct {
 	Rect       image.Rectangle
 }
 
-func IsSamePerson(rec *face.Recognizer, frames []FrameData) bool {
-	face1 := frames[0]
-
-	rec.SetSamples([]face.Descriptor{
-		face1.Descriptor,
-	}, []int32{0})
-
+// IsSamePerson reports whether every frame is within threshold of the first
+// one, by MatchDistance.
+func IsSamePerson(frames []FrameData, threshold float64) bool {
 	for i := 1; i < len(frames); i++ {
-		if rec.ClassifyThreshold(frames[i].Descriptor, Threshold) < 0 {
+		if MatchDistance(frames[0].Descriptor, frames[i].Descriptor) > threshold {
 			return false
 		}
 	}
@@ -178,11 +208,20 @@ func ComputeDescriptorShift(frames []FrameData) float64 {
 	return total / float64(len(frames)-1)
 }
 
-func DescriptorDistance(a, b face.Descriptor) float64 {
+// MatchDistance is the squared Euclidean distance between two descriptors,
+// the metric dlib classifies with. Every match threshold applies to it.
+func MatchDistance(a, b face.Descriptor) float64 {
 	sum := 0.0
 	for i := range a {
 		diff := float64(a[i] - b[i])
 		sum += diff * diff
 	}
-	return math.Sqrt(sum)
+	return sum
+}
+
+// DescriptorDistance is the Euclidean distance between two descriptors. It
+// measures how much a face changes across liveness frames; matches use
+// MatchDistance.
+func DescriptorDistance(a, b face.Descriptor) float64 {
+	return math.Sqrt(MatchDistance(a, b))
 }
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/Kagami/go-face"
+)
+
+// faceAt returns a face whose descriptor differs from the zero descriptor by
+// offset in its first component.
+func faceAt(offset float32) *face.Face {
+	var descriptor face.Descriptor
+	descriptor[0] = offset
+	return &face.Face{Descriptor: descriptor}
+}
+
+func TestCompareFacesBoundary(t *testing.T) {
+	tests := []struct {
+		name      string
+		offset    float32
+		threshold float64
+		distance  float64
+		match     bool
+	}{
+		{"identical", 0, Threshold, 0, true},
+		{"on the threshold", 0.5, 0.25, 0.25, true},
+		{"past the threshold", 0.5009765625, 0.25, 0.2509775161743164, false},
+		// 0.3 apart is 0.09 squared: a match, although the plain
+		// Euclidean distance is past the default threshold.
+		{"within the default", 0.3, Threshold, 0.09, true},
+		{"past the default", 0.35, Threshold, 0.1225, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison, err := CompareFaces(faceAt(0), faceAt(tt.offset), tt.threshold)
+			if tt.match && err != nil {
+				t.Fatalf("CompareFaces() error = %v, want nil", err)
+			}
+			if !tt.match && err != ErrNoMatch {
+				t.Fatalf("CompareFaces() error = %v, want ErrNoMatch", err)
+			}
+			if comparison.Match != tt.match {
+				t.Errorf("Match = %v, want %v", comparison.Match, tt.match)
+			}
+			if diff := comparison.Distance - tt.distance; diff > 1e-6 || diff < -1e-6 {
+				t.Errorf("Distance = %v, want %v", comparison.Distance, tt.distance)
+			}
+		})
+	}
+}
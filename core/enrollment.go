@@ -0,0 +1,136 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/Kagami/go-face"
+)
+
+var (
+	ErrPoseNotMatched = errors.New("no frame matches the requested pose")
+	ErrNotSamePerson  = errors.New("frames do not show the same person")
+)
+
+// EnrollmentPose names one of the head positions requested during guided
+// enrollment. Left and right are from the subject's point of view.
+type EnrollmentPose string
+
+const (
+	PoseFront       EnrollmentPose = "front"
+	PoseSlightLeft  EnrollmentPose = "slight_left"
+	PoseSlightRight EnrollmentPose = "slight_right"
+)
+
+var EnrollmentPoses = []EnrollmentPose{PoseFront, PoseSlightLeft, PoseSlightRight}
+
+// PoseLimits bounds, in degrees, what counts as a frontal face and how far
+// the head must turn for the slight-left and slight-right poses.
+type PoseLimits struct {
+	MaxYaw     float64
+	MaxPitch   float64
+	MaxRoll    float64
+	MinTurnYaw float64
+	MaxTurnYaw float64
+}
+
+// Matches reports whether the head pose fits the requested enrollment pose.
+// Turning to the subject's left moves the face towards the image's right,
+// which is a positive yaw.
+func (p Pose) Matches(target EnrollmentPose, limits PoseLimits) bool {
+	if math.Abs(p.Pitch) > limits.MaxPitch || math.Abs(p.Roll) > limits.MaxRoll {
+		return false
+	}
+
+	switch target {
+	case PoseFront:
+		return math.Abs(p.Yaw) <= limits.MaxYaw
+	case PoseSlightLeft:
+		return p.Yaw >= limits.MinTurnYaw && p.Yaw <= limits.MaxTurnYaw
+	case PoseSlightRight:
+		return -p.Yaw >= limits.MinTurnYaw && -p.Yaw <= limits.MaxTurnYaw
+	}
+	return false
+}
+
+type EnrollmentSample struct {
+	Pose     EnrollmentPose
+	Image    []byte
+	Face     face.Face
+	HeadPose Pose
+	Quality  Quality
+}
+
+// FrameError points at the submitted frame that made enrollment fail.
+type FrameError struct {
+	Pose  EnrollmentPose
+	Index int
+	Err   error
+}
+
+func (e *FrameError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("%s: %v", e.Pose, e.Err)
+	}
+	return fmt.Sprintf("%s frame %d: %v", e.Pose, e.Index+1, e.Err)
+}
+
+func (e *FrameError) Unwrap() error {
+	return e.Err
+}
+
+// SelectEnrollmentSamples detects the face on every submitted frame, keeps
+// the frames whose head pose matches the pose they were captured for, checks
+// that all kept frames show the same person, within threshold, and returns
+// the best quality frame for each pose.
+func SelectEnrollmentSamples(frames map[EnrollmentPose][][]byte, limits PoseLimits, threshold float64) ([]EnrollmentSample, error) {
+	var selected []EnrollmentSample
+	var all []FrameData
+
+	for _, target := range EnrollmentPoses {
+		var best *EnrollmentSample
+		for i, data := range frames[target] {
+			detected, err := CheckFaceData(data)
+			if err != nil {
+				var countErr *FaceCountError
+				if !errors.As(err, &countErr) {
+					err = ErrDecodingImage
+				}
+				return nil, &FrameError{Pose: target, Index: i, Err: err}
+			}
+
+			headPose, err := EstimatePose(*detected)
+			if err != nil || !headPose.Matches(target, limits) {
+				continue
+			}
+
+			quality, err := AssessQualityJPEG(data, detected.Rectangle)
+			if err != nil {
+				return nil, &FrameError{Pose: target, Index: i, Err: err}
+			}
+
+			all = append(all, FrameData{Descriptor: detected.Descriptor, Rect: detected.Rectangle})
+			if best == nil || quality.Score > best.Quality.Score {
+				best = &EnrollmentSample{
+					Pose:     target,
+					Image:    data,
+					Face:     *detected,
+					HeadPose: headPose,
+					Quality:  quality,
+				}
+			}
+		}
+
+		if best == nil {
+			return nil, &FrameError{Pose: target, Index: -1, Err: ErrPoseNotMatched}
+		}
+		selected = append(selected, *best)
+	}
+
+	if !IsSamePerson(all, threshold) {
+		return nil, ErrNotSamePerson
+	}
+
+	return selected, nil
+}
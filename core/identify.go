@@ -0,0 +1,41 @@
+package core
+
+import (
+	"math"
+
+	"github.com/Kagami/go-face"
+)
+
+// Identity is one enrolled descriptor. A user may appear several times, once
+// per enrollment sample.
+type Identity struct {
+	UserID     int
+	Descriptor face.Descriptor
+}
+
+type Identification struct {
+	UserID   int
+	Distance float64
+	Matched  bool
+}
+
+// IdentifyFace finds the enrolled identity closest to descriptor, by
+// MatchDistance. Matched is false, and UserID zero, when even the closest one
+// is not within threshold; Distance is +Inf when the gallery is empty.
+func IdentifyFace(descriptor face.Descriptor, gallery []Identity, threshold float64) Identification {
+	best := Identification{Distance: math.Inf(1)}
+	for _, identity := range gallery {
+		distance := MatchDistance(identity.Descriptor, descriptor)
+		if distance < best.Distance {
+			best.UserID = identity.UserID
+			best.Distance = distance
+		}
+	}
+
+	if best.Distance > threshold {
+		best.UserID = 0
+		return best
+	}
+	best.Matched = true
+	return best
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func TestIdentifyFace(t *testing.T) {
+	gallery := []Identity{
+		{UserID: 1, Descriptor: faceAt(0).Descriptor},
+		{UserID: 2, Descriptor: faceAt(1).Descriptor},
+		// A second sample of user 1.
+		{UserID: 1, Descriptor: faceAt(0.5).Descriptor},
+	}
+
+	tests := []struct {
+		name     string
+		offset   float32
+		gallery  []Identity
+		userID   int
+		distance float64
+		matched  bool
+	}{
+		{"exact sample", 0, gallery, 1, 0, true},
+		{"closest sample", 0.6, gallery, 1, 0.01, true},
+		{"other user", 0.9, gallery, 2, 0.01, true},
+		// 0.25 from user 1's second sample is 0.0625 squared, a match
+		// although the Euclidean distance is past the threshold.
+		{"within the squared threshold", 0.25, gallery, 1, 0.0625, true},
+		{"unknown face", -0.5, gallery, 0, 0.25, false},
+		{"empty gallery", 0, nil, 0, math.Inf(1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := IdentifyFace(faceAt(tt.offset).Descriptor, tt.gallery, Threshold)
+			if got.UserID != tt.userID || got.Matched != tt.matched {
+				t.Errorf("IdentifyFace() = user %d, matched %v, want user %d, matched %v", got.UserID, got.Matched, tt.userID, tt.matched)
+			}
+			if math.IsInf(tt.distance, 1) {
+				if !math.IsInf(got.Distance, 1) {
+					t.Errorf("Distance = %v, want +Inf", got.Distance)
+				}
+			} else if math.Abs(got.Distance-tt.distance) > 1e-6 {
+				t.Errorf("Distance = %v, want %v", got.Distance, tt.distance)
+			}
+		})
+	}
+}
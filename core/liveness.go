@@ -0,0 +1,30 @@
+package core
+
+import "errors"
+
+var ErrNotLive = errors.New("liveness check failed")
+
+// LivenessThresholds bounds the motion signals of a frame sequence: a live
+// face keeps its position steady (small rectangle motion) while its
+// expression and lighting change a little between frames (descriptor shift).
+type LivenessThresholds struct {
+	MaxRectMotion      float64
+	MinDescriptorShift float64
+}
+
+type LivenessResult struct {
+	RectMotion      float64
+	DescriptorShift float64
+	Live            bool
+}
+
+func CheckLiveness(frames []FrameData, thresholds LivenessThresholds) LivenessResult {
+	rectMotion := ComputeRectangleMotion(frames)
+	descriptorShift := ComputeDescriptorShift(frames)
+
+	return LivenessResult{
+		RectMotion:      rectMotion,
+		DescriptorShift: descriptorShift,
+		Live:            rectMotion <= thresholds.MaxRectMotion && descriptorShift >= thresholds.MinDescriptorShift,
+	}
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/Kagami/go-face"
+)
+
+var ErrMultipleFaces = errors.New("multiple faces found")
+
+// MultiFacePolicy decides which face to use when an image shows more than
+// one person.
+type MultiFacePolicy string
+
+const (
+	MultiFaceReject  MultiFacePolicy = "reject"
+	MultiFaceLargest MultiFacePolicy = "largest"
+	MultiFaceCentral MultiFacePolicy = "central"
+)
+
+// FacePolicy is applied by CheckFaceData.
+var FacePolicy = MultiFaceReject
+
+func ParseMultiFacePolicy(s string) (MultiFacePolicy, error) {
+	switch policy := MultiFacePolicy(s); policy {
+	case MultiFaceReject, MultiFaceLargest, MultiFaceCentral:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown multi-face policy %q", s)
+}
+
+// FaceCountError reports how many faces were found when the image did not
+// contain exactly one usable face.
+type FaceCountError struct {
+	Count int
+}
+
+func (e *FaceCountError) Error() string {
+	if e.Count == 0 {
+		return ErrNoFaceFound.Error()
+	}
+	return fmt.Sprintf("%v: %d", ErrMultipleFaces, e.Count)
+}
+
+func (e *FaceCountError) Is(target error) bool {
+	switch target {
+	case ErrNoFaceFound:
+		return e.Count == 0
+	case ErrMultipleFaces:
+		return e.Count > 1
+	}
+	return false
+}
+
+// SelectFace picks one face out of faces according to policy. bounds is the
+// image rectangle, used by the central policy.
+func SelectFace(faces []face.Face, bounds image.Rectangle, policy MultiFacePolicy) (*face.Face, error) {
+	switch {
+	case len(faces) == 0:
+		return nil, &FaceCountError{Count: 0}
+	case len(faces) == 1:
+		return &faces[0], nil
+	}
+
+	switch policy {
+	case MultiFaceLargest:
+		return LargestFace(faces), nil
+	case MultiFaceCentral:
+		return centralFace(faces, bounds), nil
+	}
+	return nil, &FaceCountError{Count: len(faces)}
+}
+
+func LargestFace(faces []face.Face) *face.Face {
+	var best *face.Face
+	for i := range faces {
+		if best == nil || area(faces[i].Rectangle) > area(best.Rectangle) {
+			best = &faces[i]
+		}
+	}
+	return best
+}
+
+func centralFace(faces []face.Face, bounds image.Rectangle) *face.Face {
+	cx := (bounds.Min.X + bounds.Max.X) / 2
+	cy := (bounds.Min.Y + bounds.Max.Y) / 2
+
+	var best *face.Face
+	bestDist := 0
+	for i := range faces {
+		r := faces[i].Rectangle
+		dx := (r.Min.X+r.Max.X)/2 - cx
+		dy := (r.Min.Y+r.Max.Y)/2 - cy
+		if dist := dx*dx + dy*dy; best == nil || dist < bestDist {
+			best, bestDist = &faces[i], dist
+		}
+	}
+	return best
+}
+
+func area(r image.Rectangle) int {
+	return r.Dx() * r.Dy()
+}
+
+// CheckFaceData detects the faces on a JPEG image and returns the one chosen
+// by FacePolicy. The error is a *FaceCountError when no face, or several
+// faces under the reject policy, are found.
+func CheckFaceData(imageData []byte) (*face.Face, error) {
+	faces, err := recognize(func() ([]face.Face, error) { return Rec.Recognize(imageData) })
+	if err != nil {
+		return nil, fmt.Errorf("error recognizing image: %v", err)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, ErrDecodingImage
+	}
+
+	return SelectFace(faces, image.Rect(0, 0, config.Width, config.Height), FacePolicy)
+}
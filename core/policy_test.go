@@ -0,0 +1,85 @@
+package core
+
+import (
+	"errors"
+	"image"
+	"testing"
+
+	"github.com/Kagami/go-face"
+)
+
+func TestSelectFace(t *testing.T) {
+	bounds := image.Rect(0, 0, 400, 300)
+	// A large face in the corner and a smaller one in the middle.
+	corner := face.Face{Rectangle: image.Rect(0, 0, 150, 150)}
+	central := face.Face{Rectangle: image.Rect(170, 120, 230, 180)}
+	edge := face.Face{Rectangle: image.Rect(300, 100, 380, 180)}
+
+	tests := []struct {
+		name      string
+		faces     []face.Face
+		policy    MultiFacePolicy
+		want      image.Rectangle
+		wantCount int // of the expected *FaceCountError, -1 when none
+	}{
+		{"single face", []face.Face{edge}, MultiFaceReject, edge.Rectangle, -1},
+		{"no face", nil, MultiFaceLargest, image.Rectangle{}, 0},
+		{"reject", []face.Face{corner, central}, MultiFaceReject, image.Rectangle{}, 2},
+		{"unknown policy rejects", []face.Face{corner, central, edge}, "", image.Rectangle{}, 3},
+		{"largest", []face.Face{central, corner, edge}, MultiFaceLargest, corner.Rectangle, -1},
+		{"central", []face.Face{corner, edge, central}, MultiFaceCentral, central.Rectangle, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectFace(tt.faces, bounds, tt.policy)
+
+			if tt.wantCount >= 0 {
+				var countErr *FaceCountError
+				if !errors.As(err, &countErr) || countErr.Count != tt.wantCount {
+					t.Fatalf("SelectFace() error = %v, want a FaceCountError of %d faces", err, tt.wantCount)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SelectFace() error = %v", err)
+			}
+			if got.Rectangle != tt.want {
+				t.Errorf("SelectFace() picked %v, want %v", got.Rectangle, tt.want)
+			}
+		})
+	}
+}
+
+func TestFaceCountErrorIs(t *testing.T) {
+	tests := []struct {
+		count         int
+		noFace        bool
+		multipleFaces bool
+	}{
+		{0, true, false},
+		{2, false, true},
+		{5, false, true},
+	}
+
+	for _, tt := range tests {
+		err := error(&FaceCountError{Count: tt.count})
+		if errors.Is(err, ErrNoFaceFound) != tt.noFace {
+			t.Errorf("errors.Is(%d faces, ErrNoFaceFound) = %v", tt.count, !tt.noFace)
+		}
+		if errors.Is(err, ErrMultipleFaces) != tt.multipleFaces {
+			t.Errorf("errors.Is(%d faces, ErrMultipleFaces) = %v", tt.count, !tt.multipleFaces)
+		}
+	}
+}
+
+func TestParseMultiFacePolicy(t *testing.T) {
+	for _, s := range []string{"reject", "largest", "central"} {
+		if policy, err := ParseMultiFacePolicy(s); err != nil || string(policy) != s {
+			t.Errorf("ParseMultiFacePolicy(%q) = %q, %v", s, policy, err)
+		}
+	}
+	if _, err := ParseMultiFacePolicy("biggest"); err == nil {
+		t.Error("ParseMultiFacePolicy(\"biggest\") succeeded")
+	}
+}
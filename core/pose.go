@@ -0,0 +1,125 @@
+package core
+
+import (
+	"errors"
+	"image"
+	"math"
+
+	"github.com/Kagami/go-face"
+)
+
+var ErrNoLandmarks = errors.New("face has no landmarks")
+
+// Rough facial proportions relative to the detection rectangle, used to turn
+// landmark offsets into angles. They are approximations for dlib's frontal
+// detector, good enough to tell "looking at the camera" from "looking away".
+// The neutral offsets place the nose point below the eye line of a frontal
+// face: the 5 point predictor marks the base of the nose, the 68 point one
+// its tip, which sits higher.
+const (
+	noseDepthRatio        = 0.2
+	neutralNoseBaseOffset = 0.25
+	neutralNoseTipOffset  = 0.2
+)
+
+type Pose struct {
+	Yaw   float64 `json:"yaw"`
+	Pitch float64 `json:"pitch"`
+	Roll  float64 `json:"roll"`
+}
+
+// IsFrontal reports whether every angle (in degrees) is within its limit.
+func (p Pose) IsFrontal(maxYaw, maxPitch, maxRoll float64) bool {
+	return math.Abs(p.Yaw) <= maxYaw &&
+		math.Abs(p.Pitch) <= maxPitch &&
+		math.Abs(p.Roll) <= maxRoll
+}
+
+// EstimatePose approximates yaw, pitch and roll in degrees from the eye and
+// nose landmarks of a detected face. Positive yaw means the face is turned
+// towards the image's right, positive pitch means the face is tilted up and
+// positive roll is a clockwise tilt in the image.
+func EstimatePose(f face.Face) (Pose, error) {
+	leftEye, rightEye, nose, neutralOffset, ok := keyLandmarks(f.Shapes)
+	if !ok {
+		return Pose{}, ErrNoLandmarks
+	}
+
+	width := float64(f.Rectangle.Dx())
+	height := float64(f.Rectangle.Dy())
+	if width <= 0 || height <= 0 {
+		return Pose{}, ErrNoLandmarks
+	}
+
+	roll := math.Atan2(rightEye.Y-leftEye.Y, rightEye.X-leftEye.X)
+
+	// Express the nose relative to the midpoint between the eyes, in a frame
+	// aligned with the eye line so roll does not leak into yaw and pitch.
+	midX := (leftEye.X + rightEye.X) / 2
+	midY := (leftEye.Y + rightEye.Y) / 2
+	dx := nose.X - midX
+	dy := nose.Y - midY
+	cos, sin := math.Cos(-roll), math.Sin(-roll)
+	alignedX := dx*cos - dy*sin
+	alignedY := dx*sin + dy*cos
+
+	yaw := math.Asin(clamp(alignedX / (noseDepthRatio * width)))
+	pitch := math.Asin(clamp((neutralOffset - alignedY/height) / noseDepthRatio))
+
+	return Pose{
+		Yaw:   degrees(yaw),
+		Pitch: degrees(pitch),
+		Roll:  degrees(roll),
+	}, nil
+}
+
+type point struct{ X, Y float64 }
+
+// keyLandmarks returns the eye centres (ordered left to right in the image),
+// the nose point and its neutral offset for the 5 and 68 point dlib shape
+// predictors.
+func keyLandmarks(shapes []image.Point) (leftEye, rightEye, nose point, neutralOffset float64, ok bool) {
+	var eyeA, eyeB point
+	switch len(shapes) {
+	case 5:
+		eyeA = centroid(shapes[0:2])
+		eyeB = centroid(shapes[2:4])
+		nose = toPoint(shapes[4])
+		neutralOffset = neutralNoseBaseOffset
+	case 68:
+		eyeA = centroid(shapes[36:42])
+		eyeB = centroid(shapes[42:48])
+		nose = toPoint(shapes[30])
+		neutralOffset = neutralNoseTipOffset
+	default:
+		return point{}, point{}, point{}, 0, false
+	}
+
+	if eyeA.X > eyeB.X {
+		eyeA, eyeB = eyeB, eyeA
+	}
+	return eyeA, eyeB, nose, neutralOffset, true
+}
+
+func centroid(points []image.Point) point {
+	var c point
+	for _, p := range points {
+		c.X += float64(p.X)
+		c.Y += float64(p.Y)
+	}
+	c.X /= float64(len(points))
+	c.Y /= float64(len(points))
+	return c
+}
+
+func toPoint(p image.Point) point {
+	return point{X: float64(p.X), Y: float64(p.Y)}
+}
+
+func clamp(v float64) float64 {
+	return math.Max(-1, math.Min(1, v))
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"errors"
+	"image"
+	"math"
+	"testing"
+
+	"github.com/Kagami/go-face"
+)
+
+// fivePoints returns the landmarks of the 5 point predictor: two points per
+// eye, then the nose.
+func fivePoints(leftEye, rightEye, nose image.Point) []image.Point {
+	return []image.Point{leftEye, leftEye, rightEye, rightEye, nose}
+}
+
+// sixtyEightPoints returns 68 landmarks of which only the eyes and the nose
+// tip are set.
+func sixtyEightPoints(leftEye, rightEye, nose image.Point) []image.Point {
+	shapes := make([]image.Point, 68)
+	for i := 36; i < 42; i++ {
+		shapes[i] = leftEye
+	}
+	for i := 42; i < 48; i++ {
+		shapes[i] = rightEye
+	}
+	shapes[30] = nose
+	return shapes
+}
+
+func TestEstimatePose(t *testing.T) {
+	// On a 100x100 face with eyes 40 apart on y=40, a frontal face has the
+	// base of the nose, the 5 point landmark, 25 below the eyes and its
+	// tip, the 68 point one, 20 below them.
+	box := image.Rect(0, 0, 100, 100)
+	leftEye, rightEye := image.Pt(30, 40), image.Pt(70, 40)
+
+	tests := []struct {
+		name   string
+		shapes []image.Point
+		want   Pose
+	}{
+		{"frontal", fivePoints(leftEye, rightEye, image.Pt(50, 65)), Pose{}},
+		{"frontal, 68 points", sixtyEightPoints(leftEye, rightEye, image.Pt(50, 60)), Pose{}},
+		// The tip where the 5 point model puts the base: tilted down by
+		// asin(-0.25).
+		{"tilted down, 68 points", sixtyEightPoints(leftEye, rightEye, image.Pt(50, 65)), Pose{Pitch: -14.477512185929925}},
+		{"tilted up, 68 points", sixtyEightPoints(leftEye, rightEye, image.Pt(50, 50)), Pose{Pitch: 30}},
+		{"eyes listed right first", fivePoints(rightEye, leftEye, image.Pt(50, 65)), Pose{}},
+		// The nose 10 px off the eye midpoint, half of noseDepthRatio
+		// times the width: asin(0.5).
+		{"turned right", fivePoints(leftEye, rightEye, image.Pt(60, 65)), Pose{Yaw: 30}},
+		{"turned left", fivePoints(leftEye, rightEye, image.Pt(40, 65)), Pose{Yaw: -30}},
+		{"tilted up", fivePoints(leftEye, rightEye, image.Pt(50, 55)), Pose{Pitch: 30}},
+		{"tilted down", fivePoints(leftEye, rightEye, image.Pt(50, 75)), Pose{Pitch: -30}},
+		{"turned past the model", fivePoints(leftEye, rightEye, image.Pt(90, 65)), Pose{Yaw: 90}},
+		// Rotating the frontal face by 90 degrees only changes the roll.
+		{"rolled", fivePoints(image.Pt(60, 30), image.Pt(60, 70), image.Pt(35, 50)), Pose{Roll: 90}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EstimatePose(face.Face{Rectangle: box, Shapes: tt.shapes})
+			if err != nil {
+				t.Fatalf("EstimatePose() error = %v", err)
+			}
+			if math.Abs(got.Yaw-tt.want.Yaw) > 1e-9 || math.Abs(got.Pitch-tt.want.Pitch) > 1e-9 || math.Abs(got.Roll-tt.want.Roll) > 1e-9 {
+				t.Errorf("EstimatePose() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimatePoseErrors(t *testing.T) {
+	shapes := fivePoints(image.Pt(30, 40), image.Pt(70, 40), image.Pt(50, 65))
+
+	tests := []struct {
+		name string
+		face face.Face
+	}{
+		{"no landmarks", face.Face{Rectangle: image.Rect(0, 0, 100, 100)}},
+		{"unknown predictor", face.Face{Rectangle: image.Rect(0, 0, 100, 100), Shapes: shapes[:4]}},
+		{"empty rectangle", face.Face{Shapes: shapes}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := EstimatePose(tt.face); !errors.Is(err, ErrNoLandmarks) {
+				t.Errorf("EstimatePose() error = %v, want ErrNoLandmarks", err)
+			}
+		})
+	}
+}
+
+func TestIsFrontal(t *testing.T) {
+	tests := []struct {
+		pose Pose
+		want bool
+	}{
+		{Pose{}, true},
+		{Pose{Yaw: -20, Pitch: 15, Roll: 10}, true},
+		{Pose{Yaw: 20.5}, false},
+		{Pose{Pitch: -16}, false},
+		{Pose{Roll: 11}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.pose.IsFrontal(20, 15, 10); got != tt.want {
+			t.Errorf("%+v.IsFrontal(20, 15, 10) = %v, want %v", tt.pose, got, tt.want)
+		}
+	}
+}
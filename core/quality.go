@@ -0,0 +1,80 @@
+package core
+
+import (
+	"bytes"
+	"image"
+	"math"
+)
+
+// Reference values used to normalise the quality signals into 0..1. A face
+// crop with a Laplacian variance of sharpnessReference or a face box of
+// faceSizeReference pixels is considered good enough.
+const (
+	sharpnessReference = 100.0
+	faceSizeReference  = 160.0
+)
+
+type Quality struct {
+	Sharpness  float64 `json:"sharpness"`
+	Brightness float64 `json:"brightness"`
+	FaceSize   int     `json:"face_size"`
+	Score      float64 `json:"score"`
+}
+
+// AssessQualityJPEG decodes the image and scores the face inside rect.
+func AssessQualityJPEG(imageData []byte, rect image.Rectangle) (Quality, error) {
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return Quality{}, ErrDecodingImage
+	}
+
+	return AssessQuality(img, rect), nil
+}
+
+// AssessQuality scores the face inside rect on sharpness (variance of the
+// Laplacian), exposure (mean luminance) and size. Score is a 0..1 summary of
+// the three.
+func AssessQuality(img image.Image, rect image.Rectangle) Quality {
+	rect = rect.Intersect(img.Bounds())
+	size := min(rect.Dx(), rect.Dy())
+	if size <= 2 {
+		return Quality{FaceSize: max(size, 0)}
+	}
+
+	gray := make([][]float64, rect.Dy())
+	total := 0.0
+	for y := 0; y < rect.Dy(); y++ {
+		gray[y] = make([]float64, rect.Dx())
+		for x := 0; x < rect.Dx(); x++ {
+			r, g, b, _ := img.At(rect.Min.X+x, rect.Min.Y+y).RGBA()
+			lum := (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+			gray[y][x] = lum
+			total += lum
+		}
+	}
+	brightness := total / float64(rect.Dx()*rect.Dy())
+
+	var sum, sumSq float64
+	n := 0
+	for y := 1; y < len(gray)-1; y++ {
+		for x := 1; x < len(gray[y])-1; x++ {
+			lap := gray[y-1][x] + gray[y+1][x] + gray[y][x-1] + gray[y][x+1] - 4*gray[y][x]
+			sum += lap
+			sumSq += lap * lap
+			n++
+		}
+	}
+	mean := sum / float64(n)
+	sharpness := sumSq/float64(n) - mean*mean
+
+	sharpnessScore := math.Min(1, sharpness/sharpnessReference)
+	exposureScore := 1 - math.Abs(brightness-128)/128
+	sizeScore := math.Min(1, float64(size)/faceSizeReference)
+
+	return Quality{
+		Sharpness:  sharpness,
+		Brightness: brightness,
+		FaceSize:   size,
+		Score:      (sharpnessScore + exposureScore + sizeScore) / 3,
+	}
+}
@@ -0,0 +1,114 @@
+// Package logging sets up the structured logger of the server. Every record
+// logged with a request context carries the request id, and the redaction
+// policy keeps personal data out of the logs.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/Adedunmol/face-widget/tracing"
+)
+
+// Setup installs the default logger, which the standard log package writes
+// through as well. format is json or text.
+func Setup(w io.Writer, level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q", level)
+	}
+
+	options := &slog.HandlerOptions{Level: lvl, ReplaceAttr: redact}
+	var handler slog.Handler
+	switch format {
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return fmt.Errorf("invalid log format %q, expected json or text", format)
+	}
+
+	slog.SetDefault(slog.New(contextHandler{handler}))
+	return nil
+}
+
+// Fatal logs msg at the error level and exits.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the id of the request.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler adds the request and trace ids of the context to every
+// record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if span := tracing.SpanFromContext(ctx); span != nil {
+		record.AddAttrs(slog.String("trace_id", span.SpanContext().TraceID.String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// redactedKeys name attributes that hold personal data or secrets. Code
+// should not log them at all; this is the safety net.
+var redactedKeys = map[string]bool{
+	"email":         true,
+	"name":          true,
+	"first_name":    true,
+	"last_name":     true,
+	"given_name":    true,
+	"family_name":   true,
+	"image":         true,
+	"facial_image":  true,
+	"frames":        true,
+	"descriptor":    true,
+	"password":      true,
+	"secret":        true,
+	"token":         true,
+	"authorization": true,
+}
+
+const redacted = "[REDACTED]"
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if redactedKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	// Raw bytes are image data more often than not.
+	if a.Value.Kind() == slog.KindAny {
+		if b, ok := a.Value.Any().([]byte); ok {
+			return slog.String(a.Key, fmt.Sprintf("[%d bytes]", len(b)))
+		}
+	}
+	return a
+}
@@ -1,48 +1,120 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"net/http"
+	"log/slog"
+	"os"
 
+	"github.com/Adedunmol/face-widget/cli"
 	"github.com/Adedunmol/face-widget/core"
+	"github.com/Adedunmol/face-widget/logging"
+	"github.com/Adedunmol/face-widget/tracing"
 
+	"github.com/Adedunmol/face-widget/api"
+	"github.com/Adedunmol/face-widget/api/config"
 	"github.com/Adedunmol/face-widget/api/db"
-	"github.com/Adedunmol/face-widget/api/handlers"
+	"github.com/Adedunmol/face-widget/api/middleware"
+	"github.com/Adedunmol/face-widget/api/ratelimit"
+	"github.com/Adedunmol/face-widget/api/storage"
+	"github.com/Adedunmol/face-widget/api/token"
+	"github.com/Adedunmol/face-widget/api/webhooks"
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
 )
 
 func main() {
+	envErr := godotenv.Load()
+
+	config.Load()
+
+	if err := logging.Setup(os.Stderr, config.Cfg.Log.Level, config.Cfg.Log.Format); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	if envErr != nil {
+		slog.Warn("Could not load .env file, assuming the variables are set in the environment")
+	}
+
 	rec := core.Init()
 	defer rec.Close()
 
-	err := godotenv.Load()
+	var err error
+	core.FacePolicy, err = core.ParseMultiFacePolicy(config.Cfg.Faces.MultiFacePolicy)
 	if err != nil {
-		log.Println("Warning: Could not load .env file. Assuming environment variables are set in the environment.")
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+
+	if len(os.Args) > 1 {
+		if err := cli.Run(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := token.Init(config.Cfg.Token); err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
 	}
 
 	db.RunMigrations()
 
 	db.ConnectDB()
 
-	mux := http.NewServeMux()
+	if err := ratelimit.Init(config.Cfg.RateLimit, config.Cfg.Lockout); err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
+	}
 
-	mux.HandleFunc("POST /register", handlers.RegisterUser)
-	mux.HandleFunc("POST /verify", handlers.VerifyUser)
-	mux.HandleFunc("POST /verify_user", handlers.NewVerifyUser)
+	if err := storage.Init(config.Cfg.Storage); err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+
+	tracingCfg := config.Cfg.Tracing
+	if err := tracing.Setup(tracing.Options{
+		Exporter:    tracingCfg.Exporter,
+		Endpoint:    tracingCfg.Endpoint,
+		Headers:     tracingCfg.Headers,
+		ServiceName: tracingCfg.ServiceName,
+		SampleRatio: tracingCfg.SampleRatio,
+	}); err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+
+	mux := api.NewMux()
 
 	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type"},
-		AllowCredentials: true,
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
+		AllowedHeaders: []string{"Authorization", "Content-Type", middleware.RequestIDHeader, tracing.TraceparentHeader},
+		ExposedHeaders: []string{middleware.RequestIDHeader, "Deprecation", "Link"},
 	})
 
-	handler := c.Handler(mux)
-	serverPort := ":8080"
+	handler := c.Handler(middleware.RequestID(middleware.Trace(middleware.AccessLog(middleware.Metrics(middleware.Recover(mux))))))
+	server := api.NewServer(config.Cfg.Server, handler)
+
+	deliveries, stopDeliveries := context.WithCancel(context.Background())
+	delivered := make(chan struct{})
+	go func() {
+		webhooks.Run(deliveries, config.Cfg.Webhooks)
+		close(delivered)
+	}()
 
-	fmt.Printf("Face Recognition API server starting on port %s...", serverPort)
-	log.Fatal(http.ListenAndServe(serverPort, handler))
+	slog.Info("Face Recognition API server starting", "port", config.Cfg.Server.Port)
+	serveErr := api.Serve(server, config.Cfg.Server)
 
+	stopDeliveries()
+	<-delivered
+
+	if err := db.DB.Close(); err != nil {
+		slog.Error("Failed to close the database", "error", err)
+	}
+	flushCtx, cancel := context.WithTimeout(context.Background(), config.Cfg.Server.ShutdownTimeout)
+	if err := tracing.Shutdown(flushCtx); err != nil {
+		slog.Error("Failed to export the remaining spans", "error", err)
+	}
+	cancel()
+	if serveErr != nil {
+		// logging.Fatal skips the deferred rec.Close.
+		rec.Close()
+		logging.Fatal("Server stopped", "error", serveErr)
+	}
+	slog.Info("Server stopped")
 }
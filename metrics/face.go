@@ -0,0 +1,55 @@
+package metrics
+
+// The metrics of the server.
+var (
+	HTTPRequests = NewCounter(
+		"http_requests_total",
+		"HTTP requests by route, method and status.",
+		"route", "method", "status",
+	)
+	HTTPDuration = NewHistogram(
+		"http_request_duration_seconds",
+		"HTTP request latency by route, method and status.",
+		DefBuckets,
+		"route", "method", "status",
+	)
+
+	DetectionDuration = NewHistogram(
+		"face_detection_duration_seconds",
+		"Time the recognizer takes to detect and describe the faces of an image.",
+		DefBuckets,
+	)
+	ComparisonDuration = NewHistogram(
+		"face_comparison_duration_seconds",
+		"Time taken to compare a stored image with a candidate, detection included.",
+		DefBuckets,
+	)
+	RecognizerQueueDepth = NewGauge(
+		"recognizer_queue_depth",
+		"Recognizer calls waiting or running.",
+	)
+
+	VerificationDistance = NewHistogram(
+		"verification_distance",
+		"Squared descriptor distance, the one compared with the match threshold, of verifications by method and outcome (accepted or rejected).",
+		[]float64{0.02, 0.04, 0.06, 0.08, 0.1, 0.12, 0.15, 0.2, 0.3, 0.5, 0.75, 1, 1.5},
+		"method", "outcome",
+	)
+	LivenessRectMotion = NewHistogram(
+		"liveness_rect_motion",
+		"Face rectangle motion across the frames of liveness checks, in pixels.",
+		[]float64{1, 2, 5, 10, 20, 50, 100, 200},
+	)
+	LivenessDescriptorShift = NewHistogram(
+		"liveness_descriptor_shift",
+		"Descriptor shift across the frames of liveness checks.",
+		[]float64{0.01, 0.02, 0.05, 0.07, 0.1, 0.15, 0.2, 0.3, 0.5},
+	)
+
+	StorageUploadDuration = NewHistogram(
+		"storage_upload_duration_seconds",
+		"Latency of image uploads to the storage backend by outcome.",
+		DefBuckets,
+		"outcome",
+	)
+)
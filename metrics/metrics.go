@@ -0,0 +1,246 @@
+// Package metrics keeps counters, gauges and histograms and exposes them in
+// the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are latency buckets in seconds, suited to the recognizer.
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type metric interface {
+	write(b *strings.Builder)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []metric
+)
+
+func register(m metric) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, m)
+}
+
+// family holds the series of a metric, one per combination of label values.
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]interface{}
+	values map[string][]string
+}
+
+func newFamily(name, help, kind string, labels []string) family {
+	return family{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: map[string]interface{}{},
+		values: map[string][]string{},
+	}
+}
+
+// get returns the series for the label values, creating it with create.
+// The caller holds f.mu.
+func (f *family) get(values []string, create func() interface{}) interface{} {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = create()
+		f.series[key] = s
+		f.values[key] = append([]string(nil), values...)
+	}
+	return s
+}
+
+// sortedKeys returns the series keys in a stable order. The caller holds
+// f.mu.
+func (f *family) sortedKeys() []string {
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *family) header(b *strings.Builder) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+}
+
+// labelPairs renders the labels of a series, with extra pairs appended.
+func (f *family) labelPairs(values []string, extra ...string) string {
+	var pairs []string
+	for i, label := range f.labels {
+		pairs = append(pairs, label+`="`+escape(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escape(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(value string) string {
+	return escaper.Replace(value)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Counter only goes up.
+type Counter struct {
+	family
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{family: newFamily(name, help, "counter", labels)}
+	register(c)
+	return c
+}
+
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *Counter) Add(v float64, values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	total := c.get(values, func() interface{} { return new(float64) }).(*float64)
+	*total += v
+}
+
+func (c *Counter) write(b *strings.Builder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(b)
+	for _, key := range c.sortedKeys() {
+		fmt.Fprintf(b, "%s%s %s\n", c.name, c.labelPairs(c.values[key]), formatFloat(*c.series[key].(*float64)))
+	}
+}
+
+// Gauge goes up and down.
+type Gauge struct {
+	family
+}
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{family: newFamily(name, help, "gauge", labels)}
+	register(g)
+	return g
+}
+
+func (g *Gauge) Add(v float64, values ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	value := g.get(values, func() interface{} { return new(float64) }).(*float64)
+	*value += v
+}
+
+func (g *Gauge) Inc(values ...string) { g.Add(1, values...) }
+func (g *Gauge) Dec(values ...string) { g.Add(-1, values...) }
+
+func (g *Gauge) write(b *strings.Builder) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.header(b)
+	if len(g.labels) == 0 && len(g.series) == 0 {
+		// A gauge without labels reads 0 before its first change.
+		fmt.Fprintf(b, "%s 0\n", g.name)
+	}
+	for _, key := range g.sortedKeys() {
+		fmt.Fprintf(b, "%s%s %s\n", g.name, g.labelPairs(g.values[key]), formatFloat(*g.series[key].(*float64)))
+	}
+}
+
+// Histogram counts observations in cumulative buckets.
+type Histogram struct {
+	family
+	buckets []float64
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{family: newFamily(name, help, "histogram", labels), buckets: buckets}
+	register(h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(values, func() interface{} {
+		return &histogramSeries{counts: make([]uint64, len(h.buckets))}
+	}).(*histogramSeries)
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *Histogram) write(b *strings.Builder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(b)
+	for _, key := range h.sortedKeys() {
+		s := h.series[key].(*histogramSeries)
+		values := h.values[key]
+		for i, upper := range h.buckets {
+			fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, h.labelPairs(values, "le", formatFloat(upper)), s.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, h.labelPairs(values, "le", "+Inf"), s.count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", h.name, h.labelPairs(values), formatFloat(s.sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", h.name, h.labelPairs(values), s.count)
+	}
+}
+
+// Handler serves every metric in the Prometheus text exposition format.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	registryMu.Lock()
+	metrics := append([]metric(nil), registry...)
+	registryMu.Unlock()
+
+	var b strings.Builder
+	for _, m := range metrics {
+		m.write(&b)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
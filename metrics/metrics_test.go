@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric func() metric
+		want   string
+	}{
+		{
+			name: "counter",
+			metric: func() metric {
+				c := NewCounter("test_requests_total", "Requests.", "route", "status")
+				c.Inc("/b", "200")
+				c.Add(2, "/a", "500")
+				c.Inc("/b", "200")
+				return c
+			},
+			want: `# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{route="/a",status="500"} 2
+test_requests_total{route="/b",status="200"} 2
+`,
+		},
+		{
+			name: "escaped label values",
+			metric: func() metric {
+				c := NewCounter("test_escaped_total", "Escaping.", "value")
+				c.Inc("a\"b\\c\nd")
+				return c
+			},
+			want: `# HELP test_escaped_total Escaping.
+# TYPE test_escaped_total counter
+test_escaped_total{value="a\"b\\c\nd"} 1
+`,
+		},
+		{
+			name:   "unused gauge without labels",
+			metric: func() metric { return NewGauge("test_queue_depth", "Depth.") },
+			want: `# HELP test_queue_depth Depth.
+# TYPE test_queue_depth gauge
+test_queue_depth 0
+`,
+		},
+		{
+			name: "gauge",
+			metric: func() metric {
+				g := NewGauge("test_in_flight", "In flight.")
+				g.Inc()
+				g.Inc()
+				g.Dec()
+				return g
+			},
+			want: `# HELP test_in_flight In flight.
+# TYPE test_in_flight gauge
+test_in_flight 1
+`,
+		},
+		{
+			name: "histogram",
+			metric: func() metric {
+				h := NewHistogram("test_distance", "Distance.", []float64{0.1, 0.5, 1}, "outcome")
+				h.Observe(0.1, "accepted")
+				h.Observe(0.3, "accepted")
+				h.Observe(2, "accepted")
+				return h
+			},
+			want: `# HELP test_distance Distance.
+# TYPE test_distance histogram
+test_distance_bucket{outcome="accepted",le="0.1"} 1
+test_distance_bucket{outcome="accepted",le="0.5"} 2
+test_distance_bucket{outcome="accepted",le="1"} 2
+test_distance_bucket{outcome="accepted",le="+Inf"} 3
+test_distance_sum{outcome="accepted"} 2.4
+test_distance_count{outcome="accepted"} 3
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			tt.metric().write(&b)
+			if got := b.String(); got != tt.want {
+				t.Errorf("exposition:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelValueCount(t *testing.T) {
+	c := NewCounter("test_label_count_total", "Label count.", "route")
+	defer func() {
+		if recover() == nil {
+			t.Error("Inc() with a missing label value did not panic")
+		}
+	}()
+	c.Inc()
+}
+
+func TestHandler(t *testing.T) {
+	NewCounter("test_handler_total", "Handler.").Inc()
+
+	w := httptest.NewRecorder()
+	Handler(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the text format", got)
+	}
+	for _, want := range []string{"\ntest_handler_total 1\n", "# TYPE http_requests_total counter\n"} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("body lacks %q", want)
+		}
+	}
+}
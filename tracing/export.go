@@ -0,0 +1,212 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	queueSize     = 2048
+	batchSize     = 512
+	flushInterval = 5 * time.Second
+	exportTimeout = 10 * time.Second
+)
+
+// Options configure the exporter Setup installs.
+type Options struct {
+	// Exporter is otlp, console or none.
+	Exporter string
+	// Endpoint is the base URL of the OTLP/HTTP collector; spans are posted
+	// to its /v1/traces path.
+	Endpoint string
+	// Headers are sent with every export, as comma separated key=value
+	// pairs like OTEL_EXPORTER_OTLP_HEADERS.
+	Headers     string
+	ServiceName string
+	// SampleRatio is the share of new traces that are recorded. Traces
+	// started by a caller follow the caller's decision.
+	SampleRatio float64
+}
+
+type exporter interface {
+	export(ctx context.Context, spans []*Span) error
+}
+
+type provider struct {
+	exporter    exporter
+	sampleRatio float64
+
+	queue   chan *Span
+	flush   chan chan struct{}
+	done    chan struct{}
+	dropped atomic.Int64
+	once    sync.Once
+}
+
+var active atomic.Pointer[provider]
+
+func current() *provider {
+	return active.Load()
+}
+
+// Setup installs the exporter described by opts. With the none exporter
+// tracing stays disabled.
+func Setup(opts Options) error {
+	if opts.SampleRatio < 0 || opts.SampleRatio > 1 {
+		return fmt.Errorf("invalid trace sample ratio %v, expected a value in [0, 1]", opts.SampleRatio)
+	}
+
+	var exp exporter
+	switch opts.Exporter {
+	case "none", "":
+		return nil
+	case "console":
+		exp = &consoleExporter{w: os.Stdout, serviceName: opts.ServiceName}
+	case "otlp":
+		otlp, err := newOTLPExporter(opts.Endpoint, opts.Headers, opts.ServiceName)
+		if err != nil {
+			return err
+		}
+		exp = otlp
+	default:
+		return fmt.Errorf("invalid trace exporter %q, expected otlp, console or none", opts.Exporter)
+	}
+
+	p := &provider{
+		exporter:    exp,
+		sampleRatio: opts.SampleRatio,
+		queue:       make(chan *Span, queueSize),
+		flush:       make(chan chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go p.run()
+	active.Store(p)
+	return nil
+}
+
+// Shutdown exports the spans still queued and disables tracing.
+func Shutdown(ctx context.Context) error {
+	p := active.Swap(nil)
+	if p == nil {
+		return nil
+	}
+
+	flushed := make(chan struct{})
+	select {
+	case p.flush <- flushed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	p.once.Do(func() { close(p.done) })
+	return nil
+}
+
+// enqueue never blocks the request: spans are dropped while the queue is
+// full.
+func (p *provider) enqueue(span *Span) {
+	select {
+	case p.queue <- span:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+func (p *provider) run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, batchSize)
+	send := func() {
+		if dropped := p.dropped.Swap(0); dropped > 0 {
+			slog.Warn("Dropped spans, the export queue was full", "spans", dropped)
+		}
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+		if err := p.exporter.export(ctx, batch); err != nil {
+			slog.Warn("Failed to export spans", "spans", len(batch), "error", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+			if len(batch) == batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case flushed := <-p.flush:
+			for drained := false; !drained; {
+				select {
+				case span := <-p.queue:
+					batch = append(batch, span)
+				default:
+					drained = true
+				}
+			}
+			send()
+			close(flushed)
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// consoleExporter writes every span as a line of OTLP JSON.
+type consoleExporter struct {
+	mu          sync.Mutex
+	w           io.Writer
+	serviceName string
+}
+
+func (e *consoleExporter) export(_ context.Context, spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var errs []error
+	for _, span := range spans {
+		line, err := encodeSpans(e.serviceName, []*Span{span})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, err := e.w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseHeaders reads comma separated key=value pairs.
+func parseHeaders(raw string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid OTLP header %q, expected key=value", pair)
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
@@ -0,0 +1,201 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const scopeName = "github.com/Adedunmol/face-widget"
+
+// otlpExporter posts spans to an OTLP/HTTP collector with the JSON
+// encoding, which needs no generated protobuf code.
+type otlpExporter struct {
+	url         string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+}
+
+func newOTLPExporter(endpoint, rawHeaders, serviceName string) (*otlpExporter, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q", endpoint)
+	}
+	headers, err := parseHeaders(rawHeaders)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range headers {
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			headers[key] = unescaped
+		}
+	}
+
+	return &otlpExporter{
+		url:         strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		headers:     headers,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: exportTimeout},
+	}, nil
+}
+
+func (e *otlpExporter) export(ctx context.Context, spans []*Span) error {
+	body, err := encodeSpans(e.serviceName, spans)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector answered %s", resp.Status)
+	}
+	return nil
+}
+
+// The types below follow the JSON mapping of the OTLP trace protobuf
+// messages: ids are hex strings and 64-bit integers are decimal strings.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              Kind           `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	// Code is 0 for unset and 2 for error.
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func encodeSpans(serviceName string, spans []*Span) ([]byte, error) {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		encoded = append(encoded, encodeSpan(span))
+	}
+
+	return json.Marshal(otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: encodeAttrs([]Attr{String("service.name", serviceName)}),
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: scopeName},
+				Spans: encoded,
+			}},
+		}},
+	})
+}
+
+func encodeSpan(span *Span) otlpSpan {
+	span.mu.Lock()
+	defer span.mu.Unlock()
+
+	encoded := otlpSpan{
+		TraceID:           span.sc.TraceID.String(),
+		SpanID:            span.sc.SpanID.String(),
+		Name:              span.name,
+		Kind:              span.kind,
+		StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		Attributes:        encodeAttrs(span.attrs),
+	}
+	if span.parentID.IsValid() {
+		encoded.ParentSpanID = span.parentID.String()
+	}
+	if span.failed {
+		encoded.Status = otlpStatus{Code: 2, Message: span.statusMessage}
+	}
+	return encoded
+}
+
+func encodeAttrs(attrs []Attr) []otlpKeyValue {
+	encoded := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		var value otlpValue
+		switch v := attr.Value.(type) {
+		case string:
+			value.StringValue = &v
+		case bool:
+			value.BoolValue = &v
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			value.IntValue = &s
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				// JSON has no encoding for them.
+				s := strconv.FormatFloat(v, 'g', -1, 64)
+				value.StringValue = &s
+				break
+			}
+			value.DoubleValue = &v
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		encoded = append(encoded, otlpKeyValue{Key: attr.Key, Value: value})
+	}
+	return encoded
+}
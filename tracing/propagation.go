@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader carries the W3C trace context.
+const TraceparentHeader = "traceparent"
+
+// Extract reads the span context a caller sent in the traceparent header.
+// The zero SpanContext is returned when the header is missing or invalid.
+func Extract(header http.Header) SpanContext {
+	parts := strings.Split(strings.TrimSpace(header.Get(TraceparentHeader)), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}
+	}
+	// Version 00 has exactly four fields; later versions may append more.
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}
+	}
+
+	var sc SpanContext
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return SpanContext{}
+	}
+	sc.Sampled = flags[0]&1 == 1
+
+	if !sc.IsValid() {
+		return SpanContext{}
+	}
+	return sc
+}
+
+// Inject writes the span context of ctx to header, so that the service
+// called continues the trace.
+func Inject(ctx context.Context, header http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	flags := "00"
+	if span.sc.Sampled {
+		flags = "01"
+	}
+	header.Set(TraceparentHeader, "00-"+span.sc.TraceID.String()+"-"+span.sc.SpanID.String()+"-"+flags)
+}
@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+)
+
+// WrapConnector returns a connector whose connections record a client span
+// for every query and statement run within a traced context. system names
+// the database, such as postgresql.
+//
+// Queries span the time until the first row is available, not the time the
+// caller spends reading the rows.
+func WrapConnector(connector driver.Connector, system string) driver.Connector {
+	return &tracedConnector{Connector: connector, system: system}
+}
+
+type tracedConnector struct {
+	driver.Connector
+	system string
+}
+
+func (c *tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: conn, system: c.system}, nil
+}
+
+type tracedConn struct {
+	driver.Conn
+	system string
+}
+
+func (c *tracedConn) startSpan(ctx context.Context, query string) (context.Context, *Span) {
+	// Work outside of a request, such as migrations, is not traced.
+	if SpanFromContext(ctx) == nil {
+		return ctx, nil
+	}
+	return StartClient(ctx, operation(query),
+		String("db.system.name", c.system),
+		String("db.query.text", query),
+	)
+}
+
+// operation is the first keyword of a statement, which names its span.
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "query"
+	}
+	return strings.ToUpper(fields[0])
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := c.startSpan(ctx, query)
+	defer span.End()
+
+	rows, err := queryer.QueryContext(ctx, query, args)
+	span.RecordError(err)
+	return rows, err
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := c.startSpan(ctx, query)
+	defer span.End()
+
+	result, err := execer.ExecContext(ctx, query, args)
+	span.RecordError(err)
+	return result, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
@@ -0,0 +1,227 @@
+// Package tracing records the spans of a request and exports them with the
+// OpenTelemetry protocol, as OTLP/HTTP with the JSON encoding, or writes them
+// to stdout for local runs. Trace context travels in the W3C traceparent
+// header, so the spans join the traces of the callers.
+//
+// Spans are only recorded once Setup has installed an exporter: until then
+// Start returns a nil *Span, whose methods do nothing.
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+type TraceID [16]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+type SpanID [8]byte
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext identifies a span across process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Kind follows the OTLP span kinds.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// Attr is a span attribute. Values are strings, bools, ints, int64s or
+// float64s; anything else is exported as its string form.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Attr { return Attr{key, value} }
+
+func Int(key string, value int) Attr { return Attr{key, int64(value)} }
+
+func Int64(key string, value int64) Attr { return Attr{key, value} }
+
+func Float64(key string, value float64) Attr { return Attr{key, value} }
+
+func Bool(key string, value bool) Attr { return Attr{key, value} }
+
+// Span is an operation of a trace. A nil *Span is valid and records nothing.
+type Span struct {
+	sc       SpanContext
+	parentID SpanID
+	kind     Kind
+	start    time.Time
+
+	mu            sync.Mutex
+	name          string
+	end           time.Time
+	attrs         []Attr
+	failed        bool
+	statusMessage string
+	ended         bool
+}
+
+// Start begins an internal span, the child of the span of ctx, and returns a
+// copy of ctx carrying it.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	return start(ctx, SpanContext{}, KindInternal, name, attrs)
+}
+
+// StartClient begins a span around a call to another service, such as the
+// database or the image storage.
+func StartClient(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	return start(ctx, SpanContext{}, KindClient, name, attrs)
+}
+
+// StartServer begins the span of an incoming request. remote is the span
+// context the caller sent, if any.
+func StartServer(ctx context.Context, remote SpanContext, name string, attrs ...Attr) (context.Context, *Span) {
+	return start(ctx, remote, KindServer, name, attrs)
+}
+
+func start(ctx context.Context, remote SpanContext, kind Kind, name string, attrs []Attr) (context.Context, *Span) {
+	p := current()
+	if p == nil {
+		return ctx, nil
+	}
+
+	parent := remote
+	if parentSpan := SpanFromContext(ctx); parentSpan != nil {
+		parent = parentSpan.sc
+	}
+
+	span := &Span{
+		kind:  kind,
+		start: time.Now(),
+		name:  name,
+		attrs: attrs,
+	}
+	if parent.IsValid() {
+		span.sc.TraceID = parent.TraceID
+		span.sc.Sampled = parent.Sampled
+		span.parentID = parent.SpanID
+	} else {
+		span.sc.TraceID = newTraceID()
+		span.sc.Sampled = rand.Float64() < p.sampleRatio
+	}
+	span.sc.SpanID = newSpanID()
+
+	return ContextWithSpan(ctx, span), span
+}
+
+// SpanContext returns the identity of the span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetName renames the span, for names only known once the work is done.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// RecordError marks the span as failed. A nil err is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.SetError(err.Error())
+}
+
+// SetError marks the span as failed with a description of the failure.
+func (s *Span) SetError(message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = true
+	s.statusMessage = message
+}
+
+// End completes the span and hands it to the exporter if it is sampled.
+// Only the first call has an effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if p := current(); p != nil && s.sc.Sampled {
+		p.enqueue(s)
+	}
+}
+
+type contextKey struct{}
+
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, contextKey{}, span)
+}
+
+// SpanFromContext returns the span of ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(contextKey{}).(*Span)
+	return span
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		for i := range id {
+			id[i] = byte(rand.Uint32())
+		}
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		for i := range id {
+			id[i] = byte(rand.Uint32())
+		}
+	}
+	return id
+}